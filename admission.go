@@ -0,0 +1,162 @@
+package zrpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// priorities of calls, set by WithPriority
+const (
+	PriorityLow    = -1 // shed first when the server is overloaded
+	PriorityNormal = 0
+	PriorityHigh   = 1 // never shed by admission control
+)
+
+type priorityKey struct{}
+
+// WithPriority sets the priority of the calls made with ctx, PriorityNormal by default
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityFromContext returns the priority set by WithPriority, on the server
+// side the priority of the call a handler of HandleFunc is serving
+func PriorityFromContext(ctx context.Context) int {
+	priority, _ := ctx.Value(priorityKey{}).(int)
+	return priority
+}
+
+// AdmissionOption configures CoDel style load shedding. The queueing delay of
+// a call is the time between reading its request and executing it, including
+// the wait for a ConcurrencyLimit. When even the smallest delay of an Interval
+// exceeds Target, the server is overloaded: low priority calls are rejected
+// right away, and normal ones that waited longer than Target. Otherwise calls
+// that waited longer than Interval are rejected.
+type AdmissionOption struct {
+	Target   time.Duration // acceptable queueing delay, 5ms if not set
+	Interval time.Duration // window the smallest delay is taken over, 100ms if not set
+}
+
+type admission struct {
+	opt         AdmissionOption
+	mux         sync.Mutex // protect following
+	windowStart time.Time
+	windowMin   time.Duration // -1 if no call was executed in the window yet
+	lastMin     time.Duration
+	overloaded  bool
+	shed        uint64
+}
+
+func newAdmission(opt *AdmissionOption) *admission {
+	a := &admission{opt: *opt, windowMin: -1}
+	if a.opt.Target <= 0 {
+		a.opt.Target = time.Millisecond * 5
+	}
+	if a.opt.Interval <= 0 {
+		a.opt.Interval = time.Millisecond * 100
+	}
+	return a
+}
+
+// sample ends the window once Interval passed, called with a.mux held
+func (a *admission) sample(now time.Time) {
+	if now.Sub(a.windowStart) < a.opt.Interval {
+		return
+	}
+	a.lastMin = a.windowMin
+	a.overloaded = a.windowMin > a.opt.Target
+	a.windowStart = now
+	a.windowMin = -1
+}
+
+// early rejects low priority calls before their arguments are decoded
+func (a *admission) early(priority int, now time.Time) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.sample(now)
+	if a.overloaded && priority < PriorityNormal {
+		return a.reject(0)
+	}
+	return nil
+}
+
+// admit decides whether a call that waited delay is still executed
+func (a *admission) admit(delay time.Duration, priority int, now time.Time) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.sample(now)
+	if a.windowMin < 0 || delay < a.windowMin {
+		a.windowMin = delay
+	}
+	switch {
+	case priority >= PriorityHigh:
+		return nil
+	case a.overloaded && priority < PriorityNormal:
+		return a.reject(delay)
+	case a.overloaded && delay > a.opt.Target:
+		return a.reject(delay)
+	case delay > a.opt.Interval:
+		return a.reject(delay)
+	}
+	return nil
+}
+
+// reject is called with a.mux held
+func (a *admission) reject(delay time.Duration) error {
+	a.shed++
+	return fmt.Errorf("%s: call shed after %s in queue, target %s", overloadedError, delay, a.opt.Target)
+}
+
+// SetAdmission enables adaptive load shedding, a nil opt disables it again
+func (server *Server) SetAdmission(opt *AdmissionOption) {
+	server.mux.Lock()
+	defer server.mux.Unlock()
+	if opt == nil {
+		server.admission = nil
+		return
+	}
+	server.admission = newAdmission(opt)
+}
+
+func (server *Server) getAdmission() *admission {
+	server.mux.Lock()
+	defer server.mux.Unlock()
+	return server.admission
+}
+
+// shedEarly rejects a low priority call while the server is overloaded
+func (server *Server) shedEarly(priority int) error {
+	if a := server.getAdmission(); a != nil {
+		return a.early(priority, time.Now())
+	}
+	return nil
+}
+
+// shed rejects a call that waited too long since received
+func (server *Server) shed(received time.Time, priority int) error {
+	if a := server.getAdmission(); a != nil {
+		now := time.Now()
+		return a.admit(now.Sub(received), priority, now)
+	}
+	return nil
+}
+
+// admissionStatus is shown on the debug page
+func (server *Server) admissionStatus() map[string]string {
+	a := server.getAdmission()
+	if a == nil {
+		return nil
+	}
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.sample(time.Now())
+	return map[string]string{
+		"overloaded":                   fmt.Sprint(a.overloaded),
+		"min delay of the last window": a.lastMin.String(),
+		"target":                       a.opt.Target.String(),
+		"interval":                     a.opt.Interval.String(),
+		"shed":                         fmt.Sprint(a.shed),
+	}
+}
@@ -0,0 +1,64 @@
+package zrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAdmission(t *testing.T) {
+	a := newAdmission(&AdmissionOption{})
+	_assert(a.opt.Target == time.Millisecond*5 && a.opt.Interval == time.Millisecond*100, "expect default options")
+	start := time.Now()
+	a.windowStart = start
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	_assert(a.admit(time.Millisecond, PriorityNormal, at(0)) == nil, "expect a short wait to be admitted")
+	_assert(a.admit(time.Millisecond*200, PriorityNormal, at(0)) != nil, "expect a wait beyond Interval to be shed")
+	_assert(a.admit(time.Millisecond*200, PriorityHigh, at(0)) == nil, "expect high priority never to be shed")
+
+	// a standing queue: no call of the window waited less than Target
+	_assert(a.admit(time.Millisecond*20, PriorityNormal, at(100)) == nil, "expect to be admitted before overload is detected")
+	_assert(a.admit(time.Millisecond*30, PriorityNormal, at(150)) == nil, "expect to be admitted before overload is detected")
+	err := a.admit(time.Millisecond*20, PriorityNormal, at(200))
+	_assert(err != nil && IsOverloaded(ServerError(err.Error())), "expect an overloaded error, got %v", err)
+	_assert(a.admit(time.Millisecond*2, PriorityNormal, at(210)) == nil, "expect a wait within Target to be admitted")
+	_assert(a.admit(time.Millisecond, PriorityLow, at(220)) != nil, "expect low priority to be shed")
+	_assert(a.early(PriorityLow, at(230)) != nil, "expect low priority to be shed before decoding")
+	_assert(a.early(PriorityNormal, at(230)) == nil, "expect normal priority to be decoded")
+
+	// the queue drained
+	_assert(a.early(PriorityLow, at(300)) == nil, "expect low priority to be admitted again")
+	_assert(a.shed == 4, "expect 4 calls shed, got %d", a.shed)
+}
+
+func TestServer_Admission(t *testing.T) {
+	server := NewServer(&ServerOption{Admission: &AdmissionOption{Target: time.Second, Interval: time.Minute}})
+	_ = Handle(server, "Prio.Get", func(ctx context.Context, _ int) (int, error) {
+		return PriorityFromContext(ctx), nil
+	})
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go server.Accept(l)
+	defer server.Shutdown()
+	client, err := Dial("tcp", l.Addr().String())
+	_assert(err == nil, "dial error: %v", err)
+	defer func() { _ = client.Close() }()
+
+	p, err := Invoke[int, int](WithPriority(context.Background(), PriorityHigh), client, "Prio.Get", 0)
+	_assert(err == nil && p == PriorityHigh, "expect the priority to reach the handler, got %d %v", p, err)
+
+	a := server.getAdmission()
+	a.mux.Lock()
+	a.windowStart, a.overloaded = time.Now(), true
+	a.mux.Unlock()
+	_, err = Invoke[int, int](WithPriority(context.Background(), PriorityLow), client, "Prio.Get", 0)
+	_assert(IsOverloaded(err), "expect low priority to be shed, got %v", err)
+	p, err = Invoke[int, int](context.Background(), client, "Prio.Get", 0)
+	_assert(err == nil && p == PriorityNormal, "expect normal priority to be served, got %v", err)
+	_assert(server.admissionStatus()["shed"] == "1", "expect the shed call on the debug page")
+
+	server.SetAdmission(nil)
+	_, err = Invoke[int, int](WithPriority(context.Background(), PriorityLow), client, "Prio.Get", 0)
+	_assert(err == nil, "expect no shedding once disabled, got %v", err)
+}
@@ -22,6 +22,8 @@ type Call struct {
 	Args          interface{}
 	Reply         interface{}
 	Error         error
+	Metadata      map[string]string
+	Priority      int
 	Done          chan *Call
 }
 
@@ -39,12 +41,20 @@ type Client struct {
 	pending  map[uint64]*Call
 	closing  bool
 	shutdown bool
+	done     chan struct{} // closed once the receive loop has ended
 }
 
 var _ io.Closer = (*Client)(nil)
 
 var ErrShutdown = errors.New("connection has shut down")
 
+// ServerError represents an error that has been returned from the remote side
+type ServerError string
+
+func (e ServerError) Error() string {
+	return string(e)
+}
+
 func (client *Client) Close() error {
 	client.mux.Lock()
 	defer client.mux.Unlock()
@@ -61,6 +71,13 @@ func (client *Client) IsAvailable() bool {
 	return !client.closing && !client.shutdown
 }
 
+// NumPending returns the number of calls waiting for a reply
+func (client *Client) NumPending() int {
+	client.mux.Lock()
+	defer client.mux.Unlock()
+	return len(client.pending)
+}
+
 func (client *Client) registerCall(call *Call) (uint64, error) {
 	client.mux.Lock()
 	defer client.mux.Unlock()
@@ -91,6 +108,7 @@ func (client *Client) terminateCalls(err error) {
 		call.Error = err
 		call.done()
 	}
+	close(client.done)
 }
 
 func (client *Client) receive() {
@@ -106,7 +124,7 @@ func (client *Client) receive() {
 		case call == nil:
 			err = client.c.ReadBody(nil)
 		case header.Error != "":
-			call.Error = fmt.Errorf(header.Error)
+			call.Error = ServerError(header.Error)
 			err = client.c.ReadBody(nil)
 			call.done()
 		default:
@@ -134,6 +152,8 @@ func (client *Client) send(call *Call) {
 	client.header.ServiceMethod = call.ServiceMethod
 	client.header.Seq = seq
 	client.header.Error = ""
+	client.header.Metadata = call.Metadata
+	client.header.Priority = call.Priority
 
 	err = client.c.Write(&client.header, call.Args)
 	if err != nil {
@@ -177,6 +197,7 @@ func NewClientWithCodec(c codec.Codec, opt *Option) *Client {
 		opt:     opt,
 		seq:     1,
 		pending: make(map[uint64]*Call),
+		done:    make(chan struct{}),
 	}
 	go client.receive()
 	return client
@@ -189,12 +210,13 @@ func parseOptions(opts ...*Option) (*Option, error) {
 	if len(opts) > 1 {
 		return nil, errors.New(fmt.Sprintf("%d options, only 1 is needed", len(opts)))
 	}
-	opt := opts[0]
+	// copied, the same Option may be dialed with concurrently
+	opt := *opts[0]
 	opt.MagicNumber = DefaultOption.MagicNumber
 	if opt.CodecType == 0 {
 		opt.CodecType = DefaultOption.CodecType
 	}
-	return opt, nil
+	return &opt, nil
 }
 
 type clientResult struct {
@@ -260,16 +282,35 @@ func (client *Client) Call(serviceMethod string, args interface{}, reply interfa
 	if len(ctx) == 1 && ctx[0] != nil {
 		defaultCtx = ctx[0]
 	}
-	call := client.Go(serviceMethod, args, reply, make(chan *Call, 1))
+	call := &Call{
+		ServiceMethod: serviceMethod,
+		Args:          args,
+		Reply:         reply,
+		Metadata:      MetadataFromContext(defaultCtx),
+		Priority:      PriorityFromContext(defaultCtx),
+		Done:          make(chan *Call, 1),
+	}
+	client.send(call)
 	select {
 	case <-defaultCtx.Done():
 		client.removeCall(call.Seq)
-		return errors.New("rpc client: call timeout: " + defaultCtx.Err().Error())
+		return fmt.Errorf("rpc client: call timeout: %w", defaultCtx.Err())
 	case call := <-call.Done:
 		return call.Error
 	}
 }
 
+// Caller is implemented by Client, ReconnectClient and xclient.XClient,
+// the typed clients generated by zrpcgen call through it
+type Caller interface {
+	Call(serviceMethod string, args interface{}, reply interface{}, ctx ...context.Context) error
+}
+
+var (
+	_ Caller = (*Client)(nil)
+	_ Caller = (*ReconnectClient)(nil)
+)
+
 func NewClientWithHTTP(conn net.Conn, opt *Option) (*Client, error) {
 	_, _ = io.WriteString(conn, fmt.Sprintf("CONNECT %s HTTP/1.0\n\n", defaultRPCPath))
 	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
@@ -297,7 +338,9 @@ func XDial(rpcAddr string, opts ...*Option) (*Client, error) {
 		return DialHTTP("tcp", addr, opts...)
 	case "tcp":
 		return Dial("tcp", addr, opts...)
+	case "h2c":
+		return DialHTTP2("tcp", addr, opts...)
 	default:
-		return nil, fmt.Errorf("rpc client: unsupported protocol %s, expect http or tcp", protocol)
+		return nil, fmt.Errorf("rpc client: unsupported protocol %s, expect http, h2c or tcp", protocol)
 	}
 }
@@ -2,6 +2,8 @@ package zrpc
 
 import (
 	"context"
+	"encoding/json"
+	"github.com/vlzx/zrpc/codec"
 	"log"
 	"net"
 	"strings"
@@ -53,7 +55,8 @@ func TestClient_Call(t *testing.T) {
 
 	t.Run("client timeout", func(t *testing.T) {
 		client, _ := Dial("tcp", addr)
-		ctx, _ := context.WithTimeout(context.Background(), time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
 		var reply int
 		err := client.Call("Bar.Timeout", 1, &reply, ctx)
 		_assert(err != nil && strings.Contains(err.Error(), ctx.Err().Error()), "expect a timeout error")
@@ -78,3 +81,26 @@ func TestClient_Call(t *testing.T) {
 		_assert(err == nil, "no timeout limit")
 	})
 }
+
+func TestClient_JsonCodec(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(&Foo{})
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go server.Accept(l)
+	defer server.Shutdown()
+
+	client, err := Dial("tcp", l.Addr().String(), &Option{CodecType: codec.JsonType})
+	_assert(err == nil, "dial error: %v", err)
+	defer func() { _ = client.Close() }()
+	var reply int
+	err = client.Call("Foo.Sum", Args{Num1: 1, Num2: 2}, &reply, context.Background())
+	_assert(err == nil && reply == 3, "expect 3, got %d %v", reply, err)
+	// arguments and replies do not need Go types
+	var raw json.RawMessage
+	err = client.Call("Foo.Sum", json.RawMessage(`{"Num1":3,"Num2":4}`), &raw, context.Background())
+	_assert(err == nil && string(raw) == "7", "expect 7, got %s %v", raw, err)
+	err = client.Call("Foo.Unknown", json.RawMessage(`{}`), &raw, context.Background())
+	_assert(err != nil, "expect an error for an unknown method")
+	err = client.Call("Foo.Sum", Args{Num1: 5, Num2: 6}, &reply, context.Background())
+	_assert(err == nil && reply == 11, "expect the stream to stay in sync, got %d %v", reply, err)
+}
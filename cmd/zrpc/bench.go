@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/vlzx/zrpc/xclient"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func runBench(argv []string) error {
+	fs, common := newFlagSet("bench", "<address> <Service.Method> [json args]")
+	n := fs.Int("n", 1000, "number of calls")
+	c := fs.Int("c", 10, "number of concurrent callers, each with a connection of its own")
+	_ = fs.Parse(argv)
+	if fs.NArg() < 2 || *n < 1 || *c < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	args, err := parseArgs(fs.Args()[2:])
+	if err != nil {
+		return err
+	}
+	xc, err := newClient(fs.Arg(0), common)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = xc.Close() }()
+	xc.SetPoolOption(&xclient.PoolOption{MaxSize: *c})
+	serviceMethod := fs.Arg(1)
+
+	// fail early, e.g. on a wrong method name
+	var reply json.RawMessage
+	if err := call(xc, common, serviceMethod, args, &reply); err != nil {
+		return err
+	}
+
+	latencies := make([]time.Duration, *n)
+	var next, failed int64
+	var firstErr error
+	var once sync.Once
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < *c; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := atomic.AddInt64(&next, 1) - 1
+				if i >= int64(*n) {
+					return
+				}
+				var reply json.RawMessage
+				t := time.Now()
+				err := call(xc, common, serviceMethod, args, &reply)
+				latencies[i] = time.Since(t)
+				if err != nil {
+					atomic.AddInt64(&failed, 1)
+					once.Do(func() { firstErr = err })
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		return latencies[int(float64(len(latencies)-1)*p)]
+	}
+	fmt.Printf("calls:       %d (%d failed)\n", *n, failed)
+	fmt.Printf("concurrency: %d\n", *c)
+	fmt.Printf("elapsed:     %s\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("throughput:  %.1f calls/s\n", float64(*n)/elapsed.Seconds())
+	fmt.Printf("latency:     p50 %s, p90 %s, p99 %s, max %s\n",
+		percentile(0.5), percentile(0.9), percentile(0.99), latencies[len(latencies)-1])
+	if firstErr != nil {
+		fmt.Println("first error:", firstErr)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"github.com/vlzx/zrpc"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// printService prints the methods of a service as Go signatures,
+// followed by the definitions of the named types they use
+func printService(w io.Writer, desc *zrpc.ServiceDesc) {
+	p := &typePrinter{defined: make(map[string]bool)}
+	_, _ = fmt.Fprintf(w, "service %s\n", desc.Name)
+	for _, method := range desc.Methods {
+		_, _ = fmt.Fprintf(w, "\t%s(%s, %s) error\n", method.Name, p.typeString(method.ArgType), p.typeString(method.ReplyType))
+	}
+	// definitions may queue further named types
+	for i := 0; i < len(p.queue); i++ {
+		d := p.queue[i]
+		_, _ = fmt.Fprintf(w, "\ntype %s %s\n", d.Name, p.underlying(d, "\n"))
+	}
+}
+
+type typePrinter struct {
+	defined map[string]bool
+	queue   []*zrpc.TypeDesc
+}
+
+func (p *typePrinter) typeString(d *zrpc.TypeDesc) string {
+	if d == nil {
+		return "?"
+	}
+	if d.Ref != "" {
+		return d.Ref
+	}
+	if d.Name != "" {
+		// builtin types have no package
+		if strings.Contains(d.Name, ".") && !p.defined[d.Name] {
+			p.defined[d.Name] = true
+			p.queue = append(p.queue, d)
+		}
+		return d.Name
+	}
+	return p.underlying(d, "; ")
+}
+
+// underlying prints d ignoring its name, separating struct fields by sep
+func (p *typePrinter) underlying(d *zrpc.TypeDesc, sep string) string {
+	switch d.Kind {
+	case "ptr":
+		return "*" + p.typeString(d.Elem)
+	case "slice":
+		return "[]" + p.typeString(d.Elem)
+	case "array":
+		return "[" + strconv.Itoa(d.Len) + "]" + p.typeString(d.Elem)
+	case "map":
+		return "map[" + p.typeString(d.Key) + "]" + p.typeString(d.Elem)
+	case "chan":
+		return "chan " + p.typeString(d.Elem)
+	case "struct":
+		if len(d.Fields) == 0 {
+			return "struct{}"
+		}
+		fields := make([]string, len(d.Fields))
+		for i, field := range d.Fields {
+			fields[i] = field.Name + " " + p.typeString(field.Type)
+			if field.Tag != "" {
+				fields[i] += " `" + field.Tag + "`"
+			}
+		}
+		if sep == "\n" {
+			return "struct {\n\t" + strings.Join(fields, "\n\t") + "\n}"
+		}
+		return "struct { " + strings.Join(fields, sep) + " }"
+	default:
+		return d.Kind
+	}
+}
@@ -0,0 +1,210 @@
+// Command zrpc calls methods of zRPC servers from the command line.
+//
+//	zrpc call tcp@localhost:9999 Foo.Sum '{"Num1":1,"Num2":2}'
+//	zrpc list http://localhost:9999/_zrpc_/registry
+//	zrpc describe tcp@localhost:9999 Foo
+//	zrpc bench -n 10000 -c 20 tcp@localhost:9999 Foo.Sum '{"Num1":1,"Num2":2}'
+//
+// An address is anything XDial accepts (tcp@, http@, h2c@), or the URL of a
+// registry, then every call goes to one of its servers.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/vlzx/zrpc"
+	"github.com/vlzx/zrpc/codec"
+	"github.com/vlzx/zrpc/xclient"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const usage = `usage: zrpc <command> [flags] <address> [arguments]
+
+commands:
+  call <address> <Service.Method> [json args]   call a method and print the reply
+  list <address>                                list the services of the server
+  describe <address> <Service>                  show the methods and types of a service
+  bench <address> <Service.Method> [json args]  call a method repeatedly and report latencies
+
+address: protocol@addr as accepted by XDial, e.g. tcp@localhost:9999,
+         or the URL of a registry, e.g. http://localhost:9999/_zrpc_/registry
+
+run zrpc <command> -h for the flags of a command
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "call":
+		err = runCall(args)
+	case "list":
+		err = runList(args)
+	case "describe":
+		err = runDescribe(args)
+	case "bench":
+		err = runBench(args)
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "zrpc: unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "zrpc:", err)
+		os.Exit(1)
+	}
+}
+
+// commonFlags are shared by all commands
+type commonFlags struct {
+	timeout time.Duration
+	verbose bool
+}
+
+func newFlagSet(name string, synopsis string) (*flag.FlagSet, *commonFlags) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: zrpc %s [flags] %s\n", name, synopsis)
+		fs.PrintDefaults()
+	}
+	common := new(commonFlags)
+	fs.DurationVar(&common.timeout, "timeout", time.Second*10, "timeout of a call, and of connecting")
+	fs.BoolVar(&common.verbose, "v", false, "show the log of the zRPC client")
+	return fs, common
+}
+
+// newClient returns a client for an XDial address or a registry URL,
+// using the JSON codec so that arguments and replies need no Go types
+func newClient(address string, common *commonFlags) (*xclient.XClient, error) {
+	if !common.verbose {
+		log.SetOutput(io.Discard)
+	}
+	opt := &zrpc.Option{CodecType: codec.JsonType, ConnectTimeout: common.timeout}
+	var d xclient.Discovery
+	if strings.HasPrefix(address, "http://") || strings.HasPrefix(address, "https://") {
+		d = xclient.NewRegistryDiscovery(address, 0)
+	} else {
+		if !strings.Contains(address, "@") {
+			return nil, fmt.Errorf("wrong address '%s', expect protocol@addr or a registry URL", address)
+		}
+		d = xclient.NewMultiServerDiscovery([]string{address})
+	}
+	return xclient.NewXClient(d, xclient.RandomSelect, opt), nil
+}
+
+func call(xc *xclient.XClient, common *commonFlags, serviceMethod string, args interface{}, reply interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), common.timeout)
+	defer cancel()
+	return xc.Call(serviceMethod, args, reply, ctx)
+}
+
+// parseArgs returns the JSON arguments of a call, null if there are none
+func parseArgs(args []string) (json.RawMessage, error) {
+	if len(args) == 0 {
+		return json.RawMessage("null"), nil
+	}
+	if len(args) > 1 {
+		return nil, errors.New("expect the arguments as a single JSON value, quote them")
+	}
+	if !json.Valid([]byte(args[0])) {
+		return nil, fmt.Errorf("arguments are not valid JSON: %s", args[0])
+	}
+	return json.RawMessage(args[0]), nil
+}
+
+func runCall(argv []string) error {
+	fs, common := newFlagSet("call", "<address> <Service.Method> [json args]")
+	_ = fs.Parse(argv)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	args, err := parseArgs(fs.Args()[2:])
+	if err != nil {
+		return err
+	}
+	xc, err := newClient(fs.Arg(0), common)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = xc.Close() }()
+	var reply json.RawMessage
+	if err := call(xc, common, fs.Arg(1), args, &reply); err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, reply, "", "  "); err != nil {
+		out.Reset()
+		out.Write(reply)
+	}
+	fmt.Println(out.String())
+	return nil
+}
+
+func runList(argv []string) error {
+	fs, common := newFlagSet("list", "<address>")
+	_ = fs.Parse(argv)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	xc, err := newClient(fs.Arg(0), common)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = xc.Close() }()
+	var services []string
+	if err := call(xc, common, "zrpc.Reflection.ListServices", "", &services); err != nil {
+		return err
+	}
+	for _, name := range services {
+		var desc zrpc.ServiceDesc
+		if err := call(xc, common, "zrpc.Reflection.Describe", name, &desc); err != nil {
+			return err
+		}
+		methods := make([]string, len(desc.Methods))
+		for i, method := range desc.Methods {
+			methods[i] = method.Name
+		}
+		fmt.Printf("%s\t%s\n", name, strings.Join(methods, ", "))
+	}
+	return nil
+}
+
+func runDescribe(argv []string) error {
+	fs, common := newFlagSet("describe", "<address> <Service>")
+	asJSON := fs.Bool("json", false, "print the schema as JSON")
+	_ = fs.Parse(argv)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	xc, err := newClient(fs.Arg(0), common)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = xc.Close() }()
+	var desc zrpc.ServiceDesc
+	if err := call(xc, common, "zrpc.Reflection.Describe", fs.Arg(1), &desc); err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(desc)
+	}
+	printService(os.Stdout, &desc)
+	return nil
+}
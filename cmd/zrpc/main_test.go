@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/vlzx/zrpc"
+	"net"
+	"testing"
+)
+
+func _assert(condition bool, msg string, v ...interface{}) {
+	if !condition {
+		panic(fmt.Sprintf("assertion failed: "+msg, v...))
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	cases := []struct {
+		args []string
+		want string
+		ok   bool
+	}{
+		{nil, "null", true},
+		{[]string{`{"Num1":1,"Num2":2}`}, `{"Num1":1,"Num2":2}`, true},
+		{[]string{`42`}, `42`, true},
+		{[]string{`{"Num1":1`}, "", false},
+		{[]string{`{"Num1":1,`, `"Num2":2}`}, "", false},
+	}
+	for _, c := range cases {
+		got, err := parseArgs(c.args)
+		_assert((err == nil) == c.ok, "parseArgs(%q): unexpected error %v", c.args, err)
+		_assert(string(got) == c.want, "parseArgs(%q) = %s, expect %s", c.args, got, c.want)
+	}
+}
+
+type Node struct {
+	Name     string `json:"name"`
+	Children []*Node
+	Meta     map[string]int
+	Pair     [2]int
+	hidden   int
+}
+
+type Tree struct{}
+
+func (t Tree) Walk(root *Node, count *int) error { return nil }
+
+func (t Tree) Names(_ struct{}, names *[]string) error { return nil }
+
+func TestPrintService(t *testing.T) {
+	server := zrpc.NewServer()
+	_ = server.Register(&Tree{})
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go server.Accept(l)
+	defer server.Shutdown()
+
+	common := &commonFlags{timeout: zrpc.DefaultOption.ConnectTimeout}
+	xc, err := newClient("tcp@"+l.Addr().String(), common)
+	_assert(err == nil, "client error: %v", err)
+	defer func() { _ = xc.Close() }()
+	var desc zrpc.ServiceDesc
+	err = call(xc, common, "zrpc.Reflection.Describe", "Tree", &desc)
+	_assert(err == nil, "describe error: %v", err)
+
+	var out bytes.Buffer
+	printService(&out, &desc)
+	want := "service Tree\n" +
+		"\tNames(struct{}, *[]string) error\n" +
+		"\tWalk(*main.Node, *int) error\n" +
+		"\n" +
+		"type main.Node struct {\n" +
+		"\tName string `json:\"name\"`\n" +
+		"\tChildren []*main.Node\n" +
+		"\tMeta map[string]int\n" +
+		"\tPair [2]int\n" +
+		"}\n"
+	_assert(out.String() == want, "unexpected output:\n%s", out.String())
+
+	_, err = newClient("localhost:9999", common)
+	_assert(err != nil, "expect an error for an address without protocol")
+}
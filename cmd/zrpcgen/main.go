@@ -0,0 +1,247 @@
+// Command zrpcgen generates a typed client, a server interface and a
+// registration helper for zRPC services defined as Go types.
+//
+// Add a go:generate directive next to the service type and run go generate:
+//
+//	//go:generate zrpcgen -type Foo
+//	type Foo struct{}
+//
+//	func (f Foo) Sum(args Args, reply *int) error
+//
+// which writes foo_zrpc.go with
+//
+//	func NewFooClient(c zrpc.Caller) *FooClient
+//	func (c *FooClient) Sum(ctx context.Context, args Args) (int, error)
+//	type FooServer interface { Sum(args Args, reply *int) error }
+//	func RegisterFooServer(server *zrpc.Server, srv FooServer) error
+//
+// Methods are picked the way Server.Register does: exported, with an argument,
+// a reply pointer and an error result.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("zrpcgen: ")
+	typeNames := flag.String("type", "", "comma-separated list of service type names, required")
+	output := flag.String("output", "", "output file name, default <type>_zrpc.go in the package directory")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: zrpcgen -type T[,T...] [-output file] [directory]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+
+	pkg, err := parsePackage(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var services []*serviceDesc
+	for _, name := range strings.Split(*typeNames, ",") {
+		svc, err := pkg.service(strings.TrimSpace(name))
+		if err != nil {
+			log.Fatal(err)
+		}
+		services = append(services, svc)
+	}
+
+	src, err := generate(pkg, services, strings.Join(os.Args[1:], " "))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *output == "" {
+		name := strings.ToLower(strings.Split(*typeNames, ",")[0]) + "_zrpc.go"
+		*output = filepath.Join(dir, name)
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type methodDesc struct {
+	Name      string
+	ArgType   string
+	ReplyType string // without the pointer
+}
+
+type serviceDesc struct {
+	Name    string
+	Methods []methodDesc
+}
+
+type packageFiles struct {
+	name    string
+	fset    *token.FileSet
+	files   []*ast.File
+	imports map[string]string // import path by the name it is referred to in the generated code
+}
+
+func parsePackage(dir string) (*packageFiles, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	pkg := &packageFiles{fset: token.NewFileSet(), imports: make(map[string]string)}
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") || strings.HasSuffix(path, "_zrpc.go") {
+			continue
+		}
+		file, err := parser.ParseFile(pkg.fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		if pkg.name != "" && file.Name.Name != pkg.name {
+			return nil, fmt.Errorf("%s: package %s, expect %s", path, file.Name.Name, pkg.name)
+		}
+		pkg.name = file.Name.Name
+		pkg.files = append(pkg.files, file)
+	}
+	if len(pkg.files) == 0 {
+		return nil, errors.New("no Go files in " + dir)
+	}
+	return pkg, nil
+}
+
+// service collects the methods of typeName that Server.Register would register
+func (pkg *packageFiles) service(typeName string) (*serviceDesc, error) {
+	if !ast.IsExported(typeName) {
+		return nil, fmt.Errorf("%s is not exported", typeName)
+	}
+	svc := &serviceDesc{Name: typeName}
+	for _, file := range pkg.files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || receiverName(fn.Recv) != typeName || !fn.Name.IsExported() {
+				continue
+			}
+			params := expand(fn.Type.Params)
+			if len(params) != 2 || len(expand(fn.Type.Results)) != 1 {
+				continue
+			}
+			if result, ok := fn.Type.Results.List[0].Type.(*ast.Ident); !ok || result.Name != "error" {
+				continue
+			}
+			reply, ok := params[1].(*ast.StarExpr)
+			if !ok {
+				continue
+			}
+			pkg.collectImports(file, params[0])
+			pkg.collectImports(file, reply.X)
+			svc.Methods = append(svc.Methods, methodDesc{
+				Name:      fn.Name.Name,
+				ArgType:   pkg.exprString(params[0]),
+				ReplyType: pkg.exprString(reply.X),
+			})
+		}
+	}
+	if len(svc.Methods) == 0 {
+		return nil, fmt.Errorf("no methods of the form func (%s) Method(args T1, reply *T2) error found", typeName)
+	}
+	sort.Slice(svc.Methods, func(i, j int) bool { return svc.Methods[i].Name < svc.Methods[j].Name })
+	return svc, nil
+}
+
+func receiverName(recv *ast.FieldList) string {
+	if len(recv.List) != 1 {
+		return ""
+	}
+	expr := recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// expand returns one type per parameter, e.g. two for (a, b int)
+func expand(fields *ast.FieldList) []ast.Expr {
+	if fields == nil {
+		return nil
+	}
+	var types []ast.Expr
+	for _, field := range fields.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			types = append(types, field.Type)
+		}
+	}
+	return types
+}
+
+func (pkg *packageFiles) exprString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, pkg.fset, expr)
+	return buf.String()
+}
+
+// collectImports remembers the imports of file that expr refers to, e.g. time for time.Time
+func (pkg *packageFiles) collectImports(file *ast.File, expr ast.Expr) {
+	ast.Inspect(expr, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		ident, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		for _, spec := range file.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			name := path[strings.LastIndex(path, "/")+1:]
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			if name == ident.Name {
+				pkg.imports[name] = path
+			}
+		}
+		return false
+	})
+}
+
+func generate(pkg *packageFiles, services []*serviceDesc, args string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := fileTemplate.Execute(&buf, struct {
+		Args     string
+		Package  string
+		Imports  map[string]string
+		Services []*serviceDesc
+	}{args, pkg.name, pkg.imports, services})
+	if err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not compile: %v\n%s", err, buf.String())
+	}
+	return src, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func _assert(condition bool, msg string, v ...interface{}) {
+	if !condition {
+		panic(fmt.Sprintf("assertion failed: "+msg, v...))
+	}
+}
+
+func TestService(t *testing.T) {
+	pkg, err := parsePackage("testdata/svc")
+	_assert(err == nil, "parse error: %v", err)
+	svc, err := pkg.service("Svc")
+	_assert(err == nil, "service error: %v", err)
+	want := []methodDesc{
+		{Name: "Now", ArgType: "string", ReplyType: "time.Time"},
+		{Name: "Raw", ArgType: "stdjson.RawMessage", ReplyType: "map[string]int"},
+		{Name: "Swap", ArgType: "*Pair", ReplyType: "Pair"},
+	}
+	_assert(fmt.Sprint(svc.Methods) == fmt.Sprint(want), "expect methods %v, got %v", want, svc.Methods)
+	_assert(len(pkg.imports) == 2 && pkg.imports["stdjson"] == "encoding/json" && pkg.imports["time"] == "time",
+		"unexpected imports %v", pkg.imports)
+
+	_, err = pkg.service("Pair")
+	_assert(err != nil, "expect an error for a type without methods")
+	_, err = pkg.service("svc")
+	_assert(err != nil, "expect an error for an unexported type")
+}
+
+func TestGenerate(t *testing.T) {
+	cases := []struct {
+		dir    string
+		typ    string
+		golden string
+	}{
+		{"testdata/svc", "Svc", "testdata/svc/svc_zrpc.golden"},
+		// the committed example has to match a fresh go generate
+		{"../../main", "Foo", "../../main/foo_zrpc.go"},
+	}
+	for _, c := range cases {
+		pkg, err := parsePackage(c.dir)
+		_assert(err == nil, "parse error: %v", err)
+		svc, err := pkg.service(c.typ)
+		_assert(err == nil, "service error: %v", err)
+		src, err := generate(pkg, []*serviceDesc{svc}, "-type "+c.typ)
+		_assert(err == nil, "generate error: %v", err)
+		if *update && filepath.Ext(c.golden) == ".golden" {
+			_ = os.WriteFile(c.golden, src, 0644)
+		}
+		want, err := os.ReadFile(c.golden)
+		_assert(err == nil, "read error: %v", err)
+		_assert(bytes.Equal(src, want), "%s differs from the generated code:\n%s", c.golden, src)
+	}
+}
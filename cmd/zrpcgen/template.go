@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"text/template"
+)
+
+var fileTemplate = template.Must(template.New("file").Funcs(template.FuncMap{
+	"importSpec": func(name string, path string) string {
+		if path[strings.LastIndex(path, "/")+1:] == name {
+			return `"` + path + `"`
+		}
+		return name + ` "` + path + `"`
+	},
+}).Parse(`// Code generated by zrpcgen {{.Args}}; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	"github.com/vlzx/zrpc"
+{{- range $name, $path := .Imports}}
+	{{importSpec $name $path}}
+{{- end}}
+)
+{{range $svc := .Services}}
+// {{.Name}}Client is a typed client of the {{.Name}} service
+type {{.Name}}Client struct {
+	c zrpc.Caller
+}
+
+// New{{.Name}}Client calls through c, e.g. a zrpc.Client or an xclient.XClient
+func New{{.Name}}Client(c zrpc.Caller) *{{.Name}}Client {
+	return &{{.Name}}Client{c: c}
+}
+{{range .Methods}}
+func (c *{{$svc.Name}}Client) {{.Name}}(ctx context.Context, args {{.ArgType}}) ({{.ReplyType}}, error) {
+	return zrpc.Invoke[{{.ArgType}}, {{.ReplyType}}](ctx, c.c, "{{$svc.Name}}.{{.Name}}", args)
+}
+{{end}}
+// {{.Name}}Server is the interface of the {{.Name}} service
+type {{.Name}}Server interface {
+{{- range .Methods}}
+	{{.Name}}(args {{.ArgType}}, reply *{{.ReplyType}}) error
+{{- end}}
+}
+
+var _ {{.Name}}Server = (*{{.Name}})(nil)
+
+// Register{{.Name}}Server registers srv as the service {{.Name}}, whatever the type implementing it
+func Register{{.Name}}Server(server *zrpc.Server, srv {{.Name}}Server) error {
+	return server.RegisterName("{{.Name}}", srv)
+}
+{{end}}`))
@@ -0,0 +1,26 @@
+package svc
+
+import (
+	stdjson "encoding/json"
+	"time"
+)
+
+type Svc struct{}
+
+type Pair struct {
+	A, B int
+}
+
+// (args, reply *Pair) declares two parameters
+func (s *Svc) Swap(args, reply *Pair) error { return nil }
+
+func (s *Svc) Now(zone string, now *time.Time) error { return nil }
+
+func (s Svc) Raw(msg stdjson.RawMessage, reply *map[string]int) error { return nil }
+
+// skipped like Server.Register does
+func (s *Svc) unexported(args int, reply *int) error { return nil }
+func (s *Svc) NoReply(args int) error                { return nil }
+func (s *Svc) ValueReply(args int, reply int) error  { return nil }
+func (s *Svc) NoError(args int, reply *int)          {}
+func (s *Svc) Extra(a, b int, reply *int) error      { return nil }
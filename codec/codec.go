@@ -10,6 +10,8 @@ type Header struct {
 	Seq           uint64
 	Error         string
 	Timeout       time.Duration
+	Metadata      map[string]string // set by the client with zrpc.WithMetadata
+	Priority      int               // set by the client with zrpc.WithPriority
 }
 
 type Codec interface {
@@ -31,4 +33,5 @@ var NewCodecFuncMap map[uint64]NewCodecFunc
 func init() {
 	NewCodecFuncMap = make(map[uint64]NewCodecFunc)
 	NewCodecFuncMap[GobType] = NewGobCodec
+	NewCodecFuncMap[JsonType] = NewJsonCodec
 }
@@ -0,0 +1,64 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+)
+
+// JsonCodec sends the header and the body as two JSON values,
+// so a body can be given as json.RawMessage without knowing its type
+type JsonCodec struct {
+	conn io.ReadWriteCloser
+	buf  *bufio.Writer
+	enc  *json.Encoder
+	dec  *json.Decoder
+}
+
+var _ Codec = (*JsonCodec)(nil)
+
+func NewJsonCodec(conn io.ReadWriteCloser) Codec {
+	buf := bufio.NewWriter(conn)
+	return &JsonCodec{
+		conn: conn,
+		buf:  buf,
+		enc:  json.NewEncoder(buf),
+		dec:  json.NewDecoder(conn),
+	}
+}
+
+func (c *JsonCodec) ReadHeader(header *Header) error {
+	return c.dec.Decode(header)
+}
+
+func (c *JsonCodec) ReadBody(body interface{}) error {
+	if body == nil {
+		var discard json.RawMessage
+		return c.dec.Decode(&discard)
+	}
+	return c.dec.Decode(body)
+}
+
+func (c *JsonCodec) Write(header *Header, body interface{}) (err error) {
+	defer func() {
+		_ = c.buf.Flush()
+		if err != nil {
+			_ = c.Close()
+		}
+	}()
+
+	if err := c.enc.Encode(header); err != nil {
+		log.Println("rpc codec: json error encoding header:", err)
+		return err
+	}
+	if err := c.enc.Encode(body); err != nil {
+		log.Println("rpc codec: json error encoding body:", err)
+		return err
+	}
+	return nil
+}
+
+func (c *JsonCodec) Close() error {
+	return c.conn.Close()
+}
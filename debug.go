@@ -1,12 +1,18 @@
 package zrpc
 
 import (
+	_ "embed"
 	"fmt"
 	"html/template"
 	"net/http"
 )
 
-var debug = template.Must(template.ParseFiles("debug.tmpl"))
+// embedded, so that programs importing zrpc run from any directory
+//
+//go:embed debug.tmpl
+var debugText string
+
+var debug = template.Must(template.New("debug").Parse(debugText))
 
 type debugHTTP struct {
 	*Server
@@ -17,6 +23,29 @@ type debugService struct {
 	Method map[string]*methodType
 }
 
+type debugSection struct {
+	Title  string
+	Values func() map[string]string
+}
+
+type debugPage struct {
+	Services []debugService
+	Sections []debugSectionValues
+}
+
+type debugSectionValues struct {
+	Title  string
+	Values map[string]string
+}
+
+// AddDebugSection adds a table to the debug page, values is called on every
+// page view, e.g. with XClient.BreakerStates
+func (server *Server) AddDebugSection(title string, values func() map[string]string) {
+	server.mux.Lock()
+	defer server.mux.Unlock()
+	server.debugSections = append(server.debugSections, debugSection{Title: title, Values: values})
+}
+
 func (server debugHTTP) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	var services []debugService
 	server.serviceMap.Range(func(namei, svci interface{}) bool {
@@ -27,7 +56,19 @@ func (server debugHTTP) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		})
 		return true
 	})
-	err := debug.Execute(w, services)
+	server.mux.Lock()
+	sections := make([]debugSection, len(server.debugSections))
+	copy(sections, server.debugSections)
+	server.mux.Unlock()
+	page := debugPage{Services: services}
+	for _, section := range sections {
+		values := section.Values()
+		if len(values) == 0 {
+			continue // e.g. no concurrency limits set
+		}
+		page.Sections = append(page.Sections, debugSectionValues{Title: section.Title, Values: values})
+	}
+	err := debug.Execute(w, page)
 	if err != nil {
 		_, _ = fmt.Fprintln(w, "rpc debug: error executing template:", err.Error())
 	}
@@ -0,0 +1,56 @@
+package zrpc
+
+import (
+	"context"
+	"fmt"
+)
+
+// Invoke calls serviceMethod through c and returns the reply, e.g.
+//
+//	sum, err := zrpc.Invoke[Args, int](ctx, client, "Foo.Sum", Args{Num1: 1, Num2: 2})
+func Invoke[Req any, Resp any](ctx context.Context, c Caller, serviceMethod string, req Req) (Resp, error) {
+	var reply Resp
+	err := c.Call(serviceMethod, req, &reply, ctx)
+	return reply, err
+}
+
+// Future is the pending reply of an asynchronous call started by Go
+type Future[Resp any] struct {
+	client *Client
+	call   *Call
+	reply  Resp
+	err    error
+	done   chan struct{}
+}
+
+// Go calls serviceMethod asynchronously, the reply is collected by Future.Wait
+func Go[Req any, Resp any](client *Client, serviceMethod string, req Req) *Future[Resp] {
+	f := &Future[Resp]{client: client, done: make(chan struct{})}
+	f.call = client.Go(serviceMethod, req, &f.reply, make(chan *Call, 1))
+	go func() {
+		call := <-f.call.Done
+		f.err = call.Error
+		close(f.done)
+	}()
+	return f
+}
+
+// Done is closed once the call completes
+func (f *Future[Resp]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait returns the reply once the call completes, or gives up on it when ctx is done.
+// It can be called any number of times.
+func (f *Future[Resp]) Wait(ctx context.Context) (Resp, error) {
+	select {
+	case <-ctx.Done():
+		if call := f.client.removeCall(f.call.Seq); call != nil {
+			call.Error = fmt.Errorf("rpc client: call timeout: %w", ctx.Err())
+			call.done()
+		}
+		<-f.done
+	case <-f.done:
+	}
+	return f.reply, f.err
+}
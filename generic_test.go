@@ -0,0 +1,54 @@
+package zrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGeneric(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(&Foo{})
+	_ = server.Register(&Bar{})
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go server.Accept(l)
+	defer server.Shutdown()
+
+	client, err := Dial("tcp", l.Addr().String())
+	_assert(err == nil, "dial error: %v", err)
+	defer func() { _ = client.Close() }()
+
+	t.Run("invoke", func(t *testing.T) {
+		sum, err := Invoke[Args, int](context.Background(), client, "Foo.Sum", Args{Num1: 1, Num2: 2})
+		_assert(err == nil && sum == 3, "expect 3, got %d %v", sum, err)
+		product, err := Invoke[*Args, *int](context.Background(), client, "Foo.Multiply", &Args{Num1: 3, Num2: 4})
+		_assert(err == nil && product != nil && *product == 12, "expect 12, got %v %v", product, err)
+		_, err = Invoke[Args, int](context.Background(), client, "Foo.Unknown", Args{})
+		var serverErr ServerError
+		_assert(errors.As(err, &serverErr), "expect a server error, got %v", err)
+	})
+
+	t.Run("go", func(t *testing.T) {
+		futures := make([]*Future[int], 5)
+		for i := range futures {
+			futures[i] = Go[Args, int](client, "Foo.Sum", Args{Num1: i, Num2: i})
+		}
+		for i, f := range futures {
+			<-f.Done()
+			sum, err := f.Wait(context.Background())
+			_assert(err == nil && sum == 2*i, "expect %d, got %d %v", 2*i, sum, err)
+		}
+	})
+
+	t.Run("go timeout", func(t *testing.T) {
+		f := Go[int, int](client, "Bar.Timeout", 1)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer cancel()
+		_, err := f.Wait(ctx)
+		_assert(errors.Is(err, context.DeadlineExceeded), "expect a timeout error, got %v", err)
+		_, err = f.Wait(context.Background())
+		_assert(errors.Is(err, context.DeadlineExceeded), "expect the same error again, got %v", err)
+	})
+}
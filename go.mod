@@ -1,3 +1,4 @@
 module github.com/vlzx/zrpc
 
-go 1.17
+// go 1.24 for http.Protocols, used by the h2c transport in http2.go
+go 1.24
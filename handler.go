@@ -0,0 +1,101 @@
+package zrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+)
+
+var (
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
+
+// HandleFunc registers fn as the method "Service.Method" without a receiver type.
+// fn is a func(args T1, reply *T2) error, optionally taking a context.Context
+// first, which is done when the call times out. The service is created
+// if it does not exist yet, e.g.
+//
+//	server.HandleFunc("Math.Sum", func(ctx context.Context, args *Args, reply *int) error {...})
+func (server *Server) HandleFunc(serviceMethod string, fn interface{}) error {
+	// service names can not contain dots, as for RegisterName, which also
+	// keeps methods out of the built-in services
+	dot := strings.Index(serviceMethod, ".")
+	if dot <= 0 || dot == len(serviceMethod)-1 || strings.Count(serviceMethod, ".") > 1 {
+		return errors.New("rpc server: invalid name, should be service.method")
+	}
+	serviceName, methodName := serviceMethod[:dot], serviceMethod[dot+1:]
+	mType, err := newFuncMethod(fn)
+	if err != nil {
+		return fmt.Errorf("rpc server: %s: %w", serviceMethod, err)
+	}
+	for {
+		s, ok := server.serviceMap.Load(serviceName)
+		if !ok {
+			svc := &service{name: serviceName, method: map[string]*methodType{methodName: mType}}
+			if _, loaded := server.serviceMap.LoadOrStore(serviceName, svc); loaded {
+				continue
+			}
+			break
+		}
+		// copy on write, the method map is read without locks while serving
+		old := s.(*service)
+		if _, dup := old.method[methodName]; dup {
+			return errors.New("rpc server: method already exists: " + serviceMethod)
+		}
+		svc := *old
+		svc.method = make(map[string]*methodType, len(old.method)+1)
+		for name, m := range old.method {
+			svc.method[name] = m
+		}
+		svc.method[methodName] = mType
+		if server.serviceMap.CompareAndSwap(serviceName, old, &svc) {
+			break
+		}
+	}
+	log.Printf("rpc server: register %s\n", serviceMethod)
+	return nil
+}
+
+func HandleFunc(serviceMethod string, fn interface{}) error {
+	return DefaultServer.HandleFunc(serviceMethod, fn)
+}
+
+func newFuncMethod(fn interface{}) (*methodType, error) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func || fv.IsNil() {
+		return nil, errors.New("handler is not a function")
+	}
+	ft := fv.Type()
+	m := &methodType{fn: fv}
+	in := 0
+	if ft.NumIn() == 3 && ft.In(0) == typeOfContext {
+		m.withContext = true
+		in = 1
+	}
+	if ft.NumIn()-in != 2 || ft.NumOut() != 1 || ft.Out(0) != typeOfError {
+		return nil, fmt.Errorf("handler is a %s, expect func([context.Context,] args, reply) error", ft)
+	}
+	m.ArgType, m.ReplyType = ft.In(in), ft.In(in+1)
+	if m.ReplyType.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("reply type %s is not a pointer", m.ReplyType)
+	}
+	return m, nil
+}
+
+// Handle registers a typed handler as the method "Service.Method", e.g.
+//
+//	zrpc.Handle(server, "Math.Sum", func(ctx context.Context, args Args) (int, error) {...})
+func Handle[Req any, Resp any](server *Server, serviceMethod string, handler func(ctx context.Context, req Req) (Resp, error)) error {
+	return server.HandleFunc(serviceMethod, func(ctx context.Context, req Req, reply *Resp) error {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return err
+		}
+		*reply = resp
+		return nil
+	})
+}
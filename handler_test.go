@@ -0,0 +1,91 @@
+package zrpc
+
+import (
+	"context"
+	"errors"
+	"github.com/vlzx/zrpc/codec"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_HandleFunc(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(&Foo{})
+	err := server.HandleFunc("Math.Sum", func(args *Args, reply *int) error {
+		*reply = args.Num1 + args.Num2
+		return nil
+	})
+	_assert(err == nil, "handle error: %v", err)
+	err = server.HandleFunc("Math.Wait", func(ctx context.Context, d time.Duration, reply *string) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+			*reply = "done"
+			return nil
+		}
+	})
+	_assert(err == nil, "handle error: %v", err)
+	err = Handle(server, "Math.Negate", func(ctx context.Context, n int) (int, error) {
+		if n == 0 {
+			return 0, errors.New("zero")
+		}
+		return -n, nil
+	})
+	_assert(err == nil, "handle error: %v", err)
+	err = server.HandleFunc("Foo.Difference", func(args Args, reply *int) error {
+		*reply = args.Num1 - args.Num2
+		return nil
+	})
+	_assert(err == nil, "expect a function to be added to a registered service, got %v", err)
+
+	_assert(server.HandleFunc("Math.Sum", func(args Args, reply *int) error { return nil }) != nil, "expect an error for a duplicate method")
+	_assert(server.HandleFunc("Math", func(args Args, reply *int) error { return nil }) != nil, "expect an error for a name without a method")
+	_assert(server.HandleFunc("Math.v2.Sum", func(args Args, reply *int) error { return nil }) != nil, "expect an error for a service name with a dot")
+	_assert(server.HandleFunc("Math.Bad", func(args Args, reply int) error { return nil }) != nil, "expect an error for a non-pointer reply")
+	_assert(server.HandleFunc("Math.Bad", func(args Args) error { return nil }) != nil, "expect an error for a missing reply")
+	_assert(server.HandleFunc("Math.Bad", 42) != nil, "expect an error for a non-function")
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go server.Accept(l)
+	defer server.Shutdown()
+	client, err := Dial("tcp", l.Addr().String())
+	_assert(err == nil, "dial error: %v", err)
+	defer func() { _ = client.Close() }()
+
+	sum, err := Invoke[*Args, int](context.Background(), client, "Math.Sum", &Args{Num1: 1, Num2: 2})
+	_assert(err == nil && sum == 3, "expect 3, got %d %v", sum, err)
+	negated, err := Invoke[int, int](context.Background(), client, "Math.Negate", 5)
+	_assert(err == nil && negated == -5, "expect -5, got %d %v", negated, err)
+	_, err = Invoke[int, int](context.Background(), client, "Math.Negate", 0)
+	_assert(err != nil && err.Error() == "zero", "expect the handler error, got %v", err)
+	difference, err := Invoke[Args, int](context.Background(), client, "Foo.Difference", Args{Num1: 5, Num2: 2})
+	_assert(err == nil && difference == 3, "expect 3, got %d %v", difference, err)
+	sum, err = Invoke[Args, int](context.Background(), client, "Foo.Sum", Args{Num1: 5, Num2: 2})
+	_assert(err == nil && sum == 7, "expect Foo.Sum to keep working, got %d %v", sum, err)
+
+	reply, err := Invoke[time.Duration, string](context.Background(), client, "Math.Wait", time.Millisecond)
+	_assert(err == nil && reply == "done", "expect done, got %q %v", reply, err)
+	// the handler context is done once the server timeout in the request header expires
+	conn, serverConn := net.Pipe()
+	go server.ServeCodec(codec.NewGobCodec(serverConn))
+	cc := codec.NewGobCodec(conn)
+	defer func() { _ = cc.Close() }()
+	err = cc.Write(&codec.Header{ServiceMethod: "Math.Wait", Seq: 1, Timeout: time.Millisecond * 50}, time.Second)
+	_assert(err == nil, "write error: %v", err)
+	var header codec.Header
+	start := time.Now()
+	_ = cc.ReadHeader(&header)
+	_ = cc.ReadBody(nil)
+	_assert(strings.Contains(header.Error, "handle request timeout") && time.Since(start) < time.Millisecond*500,
+		"expect a timeout error, got %q after %s", header.Error, time.Since(start))
+
+	_, mType, _ := server.findService("Math.Sum")
+	_assert(mType.NumCalls() == 1, "expect 1 call, got %d", mType.NumCalls())
+	w := httptest.NewRecorder()
+	debugHTTP{server}.ServeHTTP(w, httptest.NewRequest("GET", defaultDebugPath, nil))
+	_assert(strings.Contains(w.Body.String(), "Service Math") && strings.Contains(w.Body.String(), "Negate"), "expect Math on the debug page")
+}
@@ -0,0 +1,187 @@
+package zrpc
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusServing    = "SERVING"
+	StatusNotServing = "NOT_SERVING"
+)
+
+const defaultHealthPath = "/_zrpc_/health"
+
+// healthWatchTimeout bounds a zrpc.Health.Watch long poll, the caller simply watches again
+const healthWatchTimeout = time.Minute
+
+type HealthWatchArgs struct {
+	Service string // "" for the overall status of the server
+	Status  string // the status the caller knows, Watch returns once it differs
+}
+
+// Health is the built-in health service every Server registers as "zrpc.Health",
+// with the overall status under "" and the status of each service under its name
+type Health struct {
+	server   *Server
+	mux      sync.Mutex // protect following
+	statuses map[string]string
+	watchers map[string]map[chan string]struct{}
+}
+
+func newHealth(server *Server) *Health {
+	return &Health{
+		server:   server,
+		statuses: map[string]string{"": StatusServing},
+		watchers: make(map[string]map[chan string]struct{}),
+	}
+}
+
+// status returns false if service is neither registered nor has a status set
+func (h *Health) status(service string) (string, bool) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if status, ok := h.statuses[service]; ok {
+		return status, true
+	}
+	if _, ok := h.server.serviceMap.Load(service); ok {
+		return h.statuses[""], true
+	}
+	return "", false
+}
+
+func (h *Health) setStatus(service string, status string) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.set(service, status)
+}
+
+// shutdown reports NOT_SERVING for the server and every service with a status of its own
+func (h *Health) shutdown() {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	for service := range h.statuses {
+		h.set(service, StatusNotServing)
+	}
+}
+
+// remove forgets the status of an unregistered service
+func (h *Health) remove(service string) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	delete(h.statuses, service)
+}
+
+// set is called with h.mux held
+func (h *Health) set(service string, status string) {
+	if current, ok := h.statuses[service]; ok && current == status {
+		return
+	}
+	h.statuses[service] = status
+	for ch := range h.watchers[service] {
+		notify(ch, status)
+	}
+	if service == "" {
+		// services without a status of their own follow the overall status
+		for name, chs := range h.watchers {
+			if _, ok := h.statuses[name]; ok {
+				continue
+			}
+			for ch := range chs {
+				notify(ch, status)
+			}
+		}
+	}
+}
+
+// notify replaces a status not received yet, so a slow watcher only sees the latest
+func notify(ch chan string, status string) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- status
+}
+
+func (h *Health) watch(service string) (<-chan string, func()) {
+	ch := make(chan string, 1)
+	h.mux.Lock()
+	if h.watchers[service] == nil {
+		h.watchers[service] = make(map[chan string]struct{})
+	}
+	h.watchers[service][ch] = struct{}{}
+	h.mux.Unlock()
+	return ch, func() {
+		h.mux.Lock()
+		defer h.mux.Unlock()
+		delete(h.watchers[service], ch)
+		if len(h.watchers[service]) == 0 {
+			delete(h.watchers, service)
+		}
+	}
+}
+
+// Check returns the status of service, or the overall status for ""
+func (h *Health) Check(service string, status *string) error {
+	s, ok := h.status(service)
+	if !ok {
+		return errors.New("rpc health: unknown service " + service)
+	}
+	*status = s
+	return nil
+}
+
+// Watch returns once the status of args.Service differs from args.Status,
+// or after healthWatchTimeout with the current status
+func (h *Health) Watch(args HealthWatchArgs, status *string) error {
+	ch, cancel := h.watch(args.Service)
+	defer cancel()
+	s, ok := h.status(args.Service)
+	if !ok {
+		return errors.New("rpc health: unknown service " + args.Service)
+	}
+	timeout := time.After(healthWatchTimeout)
+	for s == args.Status {
+		select {
+		case s = <-ch:
+		case <-timeout:
+			*status = s
+			return nil
+		}
+	}
+	*status = s
+	return nil
+}
+
+// SetServingStatus sets the status of service, or the overall status for "".
+// Services without a status of their own report the overall status.
+func (server *Server) SetServingStatus(service string, status string) {
+	server.health.setStatus(service, status)
+}
+
+// WatchServingStatus sends every status change of service until cancel is called
+func (server *Server) WatchServingStatus(service string) (status <-chan string, cancel func()) {
+	return server.health.watch(service)
+}
+
+// healthHTTP answers HTTP probes, e.g. of Kubernetes, at defaultHealthPath:
+// 200 if the server (or ?service=name) is SERVING, 503 otherwise
+type healthHTTP struct {
+	*Server
+}
+
+func (server healthHTTP) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	status, ok := server.health.status(req.URL.Query().Get("service"))
+	switch {
+	case !ok:
+		w.WriteHeader(http.StatusNotFound)
+		status = "SERVICE_UNKNOWN"
+	case status != StatusServing:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, _ = io.WriteString(w, status+"\n")
+}
@@ -0,0 +1,83 @@
+package zrpc
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(&Foo{})
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	done := make(chan struct{})
+	go func() {
+		server.Accept(l)
+		close(done)
+	}()
+
+	client, err := Dial("tcp", l.Addr().String())
+	_assert(err == nil, "dial error: %v", err)
+	defer func() { _ = client.Close() }()
+	check := func(service string) (string, error) {
+		var status string
+		err := client.Call("zrpc.Health.Check", service, &status, context.Background())
+		return status, err
+	}
+
+	t.Run("check", func(t *testing.T) {
+		status, err := check("")
+		_assert(err == nil && status == StatusServing, "expect SERVING, got %q %v", status, err)
+		status, err = check("Foo")
+		_assert(err == nil && status == StatusServing, "expect Foo to follow the server, got %q %v", status, err)
+		_, err = check("Unknown")
+		_assert(err != nil, "expect an error for an unknown service")
+
+		server.SetServingStatus("Foo", StatusNotServing)
+		status, _ = check("Foo")
+		_assert(status == StatusNotServing, "expect NOT_SERVING, got %q", status)
+		status, _ = check("")
+		_assert(status == StatusServing, "expect the server to stay SERVING, got %q", status)
+		server.SetServingStatus("Foo", StatusServing)
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		ch, cancel := server.WatchServingStatus("Foo")
+		defer cancel()
+		result := make(chan string, 1)
+		go func() {
+			var status string
+			_ = client.Call("zrpc.Health.Watch", HealthWatchArgs{Service: "Foo", Status: StatusServing}, &status, context.Background())
+			result <- status
+		}()
+		time.Sleep(time.Millisecond * 100)
+		server.SetServingStatus("Foo", StatusNotServing)
+		_assert(<-ch == StatusNotServing, "expect a status change")
+		_assert(<-result == StatusNotServing, "expect Watch to return the new status")
+		server.SetServingStatus("Foo", StatusServing)
+	})
+
+	t.Run("http", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		healthHTTP{server}.ServeHTTP(w, httptest.NewRequest("GET", defaultHealthPath+"?service=Foo", nil))
+		_assert(w.Code == 200, "expect 200, got %d", w.Code)
+		w = httptest.NewRecorder()
+		healthHTTP{server}.ServeHTTP(w, httptest.NewRequest("GET", defaultHealthPath+"?service=Unknown", nil))
+		_assert(w.Code == 404, "expect 404, got %d", w.Code)
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		server.Shutdown()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("expect Accept to return")
+		}
+		status, err := check("")
+		_assert(err == nil && status == StatusNotServing, "expect NOT_SERVING, got %q %v", status, err)
+		status, _ = check("Foo")
+		_assert(status == StatusNotServing, "expect NOT_SERVING, got %q", status)
+	})
+}
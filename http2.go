@@ -0,0 +1,217 @@
+package zrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/vlzx/zrpc/codec"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTP/2 transport: a client opens one long-lived POST stream per Client on
+// defaultH2Path, the request body carries requests and the response body
+// carries replies. Many Clients share a single h2 connection, and the stream
+// is an ordinary HTTP request that proxies and access logs understand.
+
+const (
+	defaultH2Path = "/_zrpc_/h2"
+	codecHeader   = "X-Zrpc-Codec"
+)
+
+// h2Stream is the server side of a stream, writes are flushed immediately
+type h2Stream struct {
+	body    io.ReadCloser
+	w       io.Writer
+	flusher http.Flusher
+	mux     sync.Mutex // protect closed, no writes once the handler returned
+	closed  bool
+}
+
+func (s *h2Stream) Read(p []byte) (int, error) {
+	return s.body.Read(p)
+}
+
+func (s *h2Stream) Write(p []byte) (int, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed {
+		return 0, io.ErrClosedPipe
+	}
+	n, err := s.w.Write(p)
+	s.flusher.Flush()
+	return n, err
+}
+
+func (s *h2Stream) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.closed = true
+	return s.body.Close()
+}
+
+type h2Handler struct {
+	*Server
+}
+
+func (server h2Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = io.WriteString(w, "Use POST method\n")
+		return
+	}
+	if req.ProtoMajor != 2 {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusHTTPVersionNotSupported)
+		_, _ = io.WriteString(w, "Use HTTP/2\n")
+		return
+	}
+	codecType, _ := strconv.ParseUint(req.Header.Get(codecHeader), 10, 64)
+	f := codec.NewCodecFuncMap[codecType]
+	if f == nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, fmt.Sprintf("invalid codec type %d\n", codecType))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Println("rpc server: http2 response writer is not a flusher")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	server.serveCodec(f(&h2Stream{body: req.Body, w: w, flusher: flusher}), newHTTPCaller(req))
+}
+
+// ServeHTTP2 serves HandleHTTP on listener, accepting HTTP/1 as well as
+// cleartext HTTP/2 (h2c) connections
+func (server *Server) ServeHTTP2(listener net.Listener) error {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{
+		Handler:   server.HandleHTTP(),
+		Protocols: &protocols,
+	}
+	if !server.trackListener(listener, true) {
+		_ = listener.Close()
+		return http.ErrServerClosed
+	}
+	defer server.trackListener(listener, false)
+	err := srv.Serve(listener)
+	if server.isShutdown() {
+		return http.ErrServerClosed
+	}
+	return err
+}
+
+func ServeHTTP2(listener net.Listener) error {
+	return DefaultServer.ServeHTTP2(listener)
+}
+
+// h2ClientStream is the client side of a stream
+type h2ClientStream struct {
+	body   io.ReadCloser
+	pw     *io.PipeWriter
+	cancel context.CancelFunc
+}
+
+func (s *h2ClientStream) Read(p []byte) (int, error) {
+	return s.body.Read(p)
+}
+
+func (s *h2ClientStream) Write(p []byte) (int, error) {
+	return s.pw.Write(p)
+}
+
+func (s *h2ClientStream) Close() error {
+	_ = s.pw.Close()
+	err := s.body.Close()
+	s.cancel()
+	return err
+}
+
+var (
+	h2TransportsMux sync.Mutex
+	h2Transports    = make(map[string]*http.Transport)
+)
+
+// h2cTransport returns a transport shared by all clients dialing the same
+// network, so that their streams are multiplexed over one connection
+func h2cTransport(network string, connectTimeout time.Duration) *http.Transport {
+	h2TransportsMux.Lock()
+	defer h2TransportsMux.Unlock()
+	key := network + "/" + connectTimeout.String()
+	t := h2Transports[key]
+	if t == nil {
+		var protocols http.Protocols
+		protocols.SetUnencryptedHTTP2(true)
+		dialer := &net.Dialer{Timeout: connectTimeout}
+		t = &http.Transport{
+			Protocols: &protocols,
+			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		}
+		h2Transports[key] = t
+	}
+	return t
+}
+
+// DialHTTP2 connects to a zRPC server over cleartext HTTP/2
+func DialHTTP2(network string, address string, opts ...*Option) (*Client, error) {
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	f := codec.NewCodecFuncMap[opt.CodecType]
+	if f == nil {
+		err := fmt.Errorf("invalid codec type %d", opt.CodecType)
+		log.Println("rpc client: codec error:", err)
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	// the context lives as long as the stream, so it is only cancelled on connect timeout or Close
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, "POST", "http://"+address+defaultH2Path, pr)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set(codecHeader, strconv.FormatUint(opt.CodecType, 10))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	var timedOut atomic.Bool
+	if opt.ConnectTimeout != 0 {
+		timer := time.AfterFunc(opt.ConnectTimeout, func() {
+			timedOut.Store(true)
+			cancel()
+		})
+		defer timer.Stop()
+	}
+	resp, err := h2cTransport(network, opt.ConnectTimeout).RoundTrip(req)
+	if err != nil {
+		cancel()
+		_ = pw.Close()
+		if timedOut.Load() {
+			return nil, fmt.Errorf("rpc client: connect timeout: expect within %s", opt.ConnectTimeout)
+		}
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		cancel()
+		_ = pw.Close()
+		_ = resp.Body.Close()
+		return nil, errors.New("unexpected HTTP response: " + resp.Status)
+	}
+	return NewClientWithCodec(f(&h2ClientStream{body: resp.Body, pw: pw, cancel: cancel}), opt), nil
+}
@@ -0,0 +1,34 @@
+package zrpc
+
+import (
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestClient_DialHTTP2(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(&Foo{})
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer func() { _ = l.Close() }()
+	go func() { _ = server.ServeHTTP2(l) }()
+
+	client, err := XDial("h2c@" + l.Addr().String())
+	_assert(err == nil, "dial error: %v", err)
+	defer func() { _ = client.Close() }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var reply int
+			err := client.Call("Foo.Sum", Args{Num1: i, Num2: i}, &reply)
+			_assert(err == nil && reply == i+i, "failed to call Foo.Sum over h2c: %v", err)
+		}(i)
+	}
+	wg.Wait()
+
+	_, err = DialHTTP("tcp", l.Addr().String())
+	_assert(err == nil, "expect the CONNECT transport to keep working next to h2c: %v", err)
+}
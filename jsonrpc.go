@@ -0,0 +1,264 @@
+package zrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	jsonRPCVersion     = "2.0"
+	defaultJSONRPCPath = "/_zrpc_/jsonrpc"
+)
+
+// standard JSON-RPC 2.0 error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	CodeServerError    = -32000
+	CodeOverloaded     = -32001 // a ConcurrencyLimit or admission control rejected the call
+	CodeRateLimited    = -32002 // a RateLimit rejected the call
+)
+
+type jsonRPCRequest struct {
+	Version  string            `json:"jsonrpc"`
+	Method   string            `json:"method"`
+	Params   json.RawMessage   `json:"params,omitempty"`
+	ID       json.RawMessage   `json:"id,omitempty"`       // nil if absent, which makes the request a notification
+	Metadata map[string]string `json:"metadata,omitempty"` // extension, the counterpart of WithMetadata
+}
+
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type jsonRPCResponse struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+var jsonNull = json.RawMessage("null")
+
+func newJSONRPCError(id json.RawMessage, code int, msg string) *jsonRPCResponse {
+	if id == nil {
+		id = jsonNull
+	}
+	return &jsonRPCResponse{
+		Version: jsonRPCVersion,
+		Error:   &jsonRPCError{Code: code, Message: msg},
+		ID:      id,
+	}
+}
+
+// handleJSONRPC handles a single request or a batch, and returns the encoded
+// response, or nil if nothing should be sent back (notifications only)
+func (server *Server) handleJSONRPC(data []byte, caller *caller) []byte {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return marshalJSONRPC(newJSONRPCError(nil, CodeParseError, "parse error: "+err.Error()))
+		}
+		if len(batch) == 0 {
+			return marshalJSONRPC(newJSONRPCError(nil, CodeInvalidRequest, "invalid request: empty batch"))
+		}
+		responses := make([]*jsonRPCResponse, len(batch))
+		var wg sync.WaitGroup
+		for i, msg := range batch {
+			wg.Add(1)
+			go func(i int, msg json.RawMessage) {
+				defer wg.Done()
+				responses[i] = server.handleJSONRPCRequest(msg, caller)
+			}(i, msg)
+		}
+		wg.Wait()
+		results := make([]*jsonRPCResponse, 0, len(responses))
+		for _, resp := range responses {
+			if resp != nil {
+				results = append(results, resp)
+			}
+		}
+		if len(results) == 0 {
+			return nil
+		}
+		return marshalJSONRPC(results)
+	}
+	resp := server.handleJSONRPCRequest(data, caller)
+	if resp == nil {
+		return nil
+	}
+	return marshalJSONRPC(resp)
+}
+
+func (server *Server) handleJSONRPCRequest(msg json.RawMessage, caller *caller) *jsonRPCResponse {
+	var req jsonRPCRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return newJSONRPCError(nil, CodeParseError, "parse error: "+err.Error())
+		}
+		return newJSONRPCError(nil, CodeInvalidRequest, "invalid request: "+err.Error())
+	}
+	if req.Version != jsonRPCVersion || req.Method == "" {
+		return newJSONRPCError(req.ID, CodeInvalidRequest, "invalid request")
+	}
+	resp := server.callJSONRPC(&req, caller)
+	if req.ID == nil {
+		return nil
+	}
+	return resp
+}
+
+func (server *Server) callJSONRPC(req *jsonRPCRequest, caller *caller) *jsonRPCResponse {
+	received := time.Now()
+	svc, mType, err := server.findService(req.Method)
+	if err != nil {
+		return newJSONRPCError(req.ID, CodeMethodNotFound, err.Error())
+	}
+	if err := server.allowRate(caller, svc.name, req.Method, req.Metadata); err != nil {
+		return newJSONRPCError(req.ID, CodeRateLimited, err.Error())
+	}
+	if err := server.rejectFull(svc.name, req.Method); err != nil {
+		return newJSONRPCError(req.ID, CodeOverloaded, err.Error())
+	}
+	argv, replyv := mType.newArgv(), mType.newReplyv()
+	argvi := argv.Interface()
+	if argv.Type().Kind() != reflect.Ptr {
+		argvi = argv.Addr().Interface()
+	}
+	if err := decodeJSONRPCParams(req.Params, argvi); err != nil {
+		return newJSONRPCError(req.ID, CodeInvalidParams, "invalid params: "+err.Error())
+	}
+	release, err := server.admit(context.Background(), svc.name, req.Method)
+	if err != nil {
+		return newJSONRPCError(req.ID, CodeOverloaded, err.Error())
+	}
+	if err := server.shed(received, PriorityNormal); err != nil {
+		release()
+		return newJSONRPCError(req.ID, CodeOverloaded, err.Error())
+	}
+	ctx := context.Background()
+	if req.Metadata != nil {
+		ctx = context.WithValue(ctx, metadataKey{}, req.Metadata)
+	}
+	err = svc.callContext(ctx, mType, argv, replyv)
+	release()
+	if err != nil {
+		return newJSONRPCError(req.ID, CodeServerError, err.Error())
+	}
+	result, err := json.Marshal(replyv.Interface())
+	if err != nil {
+		return newJSONRPCError(req.ID, CodeInternalError, "internal error: "+err.Error())
+	}
+	return &jsonRPCResponse{Version: jsonRPCVersion, Result: result, ID: req.ID}
+}
+
+// decodeJSONRPCParams decodes params into argvi. Since a zRPC method takes exactly
+// one argument, by-position params are accepted as a single element array as well.
+func decodeJSONRPCParams(params json.RawMessage, argvi interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	err := json.Unmarshal(params, argvi)
+	if err == nil {
+		return nil
+	}
+	var positional []json.RawMessage
+	if json.Unmarshal(params, &positional) != nil || len(positional) != 1 {
+		return err
+	}
+	return json.Unmarshal(positional[0], argvi)
+}
+
+func marshalJSONRPC(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Println("rpc server: jsonrpc encode response error:", err)
+		return nil
+	}
+	return data
+}
+
+// ServeJSONRPC serves JSON-RPC 2.0 messages on a single connection
+// until the client hangs up
+func (server *Server) ServeJSONRPC(conn io.ReadWriteCloser) {
+	defer func() { _ = conn.Close() }()
+	dec := json.NewDecoder(conn)
+	var caller *caller
+	sending := new(sync.Mutex)
+	wg := new(sync.WaitGroup)
+	write := func(data []byte) {
+		sending.Lock()
+		defer sending.Unlock()
+		if _, err := conn.Write(append(data, '\n')); err != nil {
+			log.Println("rpc server: jsonrpc write response error:", err)
+		}
+	}
+	for {
+		var msg json.RawMessage
+		if err := dec.Decode(&msg); err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				// the stream can not be resynchronized after a syntax error
+				write(marshalJSONRPC(newJSONRPCError(nil, CodeParseError, "parse error: "+err.Error())))
+			}
+			break
+		}
+		if caller == nil {
+			// after the first read, a TLS handshake is done by then
+			caller = newCaller(conn)
+		}
+		wg.Add(1)
+		go func(msg json.RawMessage) {
+			defer wg.Done()
+			if resp := server.handleJSONRPC(msg, caller); resp != nil {
+				write(resp)
+			}
+		}(msg)
+	}
+	wg.Wait()
+}
+
+func (server *Server) AcceptJSONRPC(listener net.Listener) {
+	server.acceptLoop(listener, server.ServeJSONRPC)
+}
+
+func AcceptJSONRPC(listener net.Listener) {
+	DefaultServer.AcceptJSONRPC(listener)
+}
+
+type jsonRPCHTTP struct {
+	*Server
+}
+
+func (server jsonRPCHTTP) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = io.WriteString(w, "Use POST method\n")
+		return
+	}
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	resp := server.handleJSONRPC(data, newHTTPCaller(req))
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resp)
+}
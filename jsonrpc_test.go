@@ -0,0 +1,142 @@
+package zrpc
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_HandleJSONRPC(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(&Foo{})
+
+	t.Run("single", func(t *testing.T) {
+		resp := string(server.handleJSONRPC([]byte(`{"jsonrpc":"2.0","method":"Foo.Sum","params":{"Num1":1,"Num2":2},"id":1}`), nil))
+		_assert(resp == `{"jsonrpc":"2.0","result":3,"id":1}`, "unexpected response %s", resp)
+	})
+	t.Run("positional params", func(t *testing.T) {
+		resp := string(server.handleJSONRPC([]byte(`{"jsonrpc":"2.0","method":"Foo.Multiply","params":[{"Num1":2,"Num2":3}],"id":"a"}`), nil))
+		_assert(resp == `{"jsonrpc":"2.0","result":6,"id":"a"}`, "unexpected response %s", resp)
+	})
+	t.Run("notification", func(t *testing.T) {
+		resp := server.handleJSONRPC([]byte(`{"jsonrpc":"2.0","method":"Foo.Sum","params":{"Num1":1,"Num2":2}}`), nil)
+		_assert(resp == nil, "expect no response for a notification, but got %s", resp)
+	})
+	t.Run("batch", func(t *testing.T) {
+		var resp []jsonRPCResponse
+		data := server.handleJSONRPC([]byte(`[
+			{"jsonrpc":"2.0","method":"Foo.Sum","params":{"Num1":1,"Num2":2},"id":1},
+			{"jsonrpc":"2.0","method":"Foo.Sum","params":{"Num1":1,"Num2":2}},
+			{"jsonrpc":"2.0","method":"Foo.Nope","id":2},
+			1
+		]`), nil)
+		_assert(json.Unmarshal(data, &resp) == nil && len(resp) == 3, "expect 3 responses, but got %s", data)
+		_assert(string(resp[0].Result) == "3", "unexpected result %s", resp[0].Result)
+		_assert(resp[1].Error != nil && resp[1].Error.Code == CodeMethodNotFound, "expect method not found")
+		_assert(resp[2].Error != nil && resp[2].Error.Code == CodeInvalidRequest, "expect invalid request")
+	})
+	t.Run("errors", func(t *testing.T) {
+		cases := map[string]int{
+			`{"jsonrpc":"2.0","method":"Foo.Sum","params":"x","id":1}`: CodeInvalidParams,
+			`{"jsonrpc":"1.0","method":"Foo.Sum","id":1}`:              CodeInvalidRequest,
+			`{"jsonrpc":"2.0","method"`:                                CodeParseError,
+			`[]`:                                                       CodeInvalidRequest,
+		}
+		for msg, code := range cases {
+			var resp jsonRPCResponse
+			data := server.handleJSONRPC([]byte(msg), nil)
+			_assert(json.Unmarshal(data, &resp) == nil && resp.Error != nil && resp.Error.Code == code,
+				"expect error code %d for %s, but got %s", code, msg, data)
+		}
+	})
+}
+
+func TestServer_ServeJSONRPC(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(&Foo{})
+	l, _ := net.Listen("tcp", ":0")
+	defer func() { _ = l.Close() }()
+	go server.AcceptJSONRPC(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	_assert(err == nil, "dial error: %v", err)
+	defer func() { _ = conn.Close() }()
+	_, _ = conn.Write([]byte(`{"jsonrpc":"2.0","method":"Foo.Sum","params":{"Num1":4,"Num2":5},"id":7}`))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	_assert(err == nil && strings.TrimSpace(line) == `{"jsonrpc":"2.0","result":9,"id":7}`, "unexpected response %s", line)
+}
+
+func TestServer_JSONRPCCaller(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(&Foo{})
+	_ = server.HandleFunc("Meta.Get", func(ctx context.Context, key string, value *string) error {
+		*value = MetadataFromContext(ctx)[key]
+		return nil
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		resp := string(server.handleJSONRPC([]byte(`{"jsonrpc":"2.0","method":"Meta.Get","params":"tenant","metadata":{"tenant":"a"},"id":1}`), nil))
+		_assert(resp == `{"jsonrpc":"2.0","result":"a","id":1}`, "expect the handler to see the metadata, got %s", resp)
+
+		server.SetRateLimit("Foo.Sum", &RateLimit{Rate: 0.001, Burst: 1, By: CallerMetadata("tenant")})
+		defer server.SetRateLimit("Foo.Sum", nil)
+		sum := func(tenant string) string {
+			return string(server.handleJSONRPC([]byte(`{"jsonrpc":"2.0","method":"Foo.Sum","params":{"Num1":1,"Num2":2},"metadata":{"tenant":"`+tenant+`"},"id":1}`), nil))
+		}
+		_assert(!strings.Contains(sum("a"), "error"), "expect the first call of tenant a to pass")
+		_assert(strings.Contains(sum("a"), "rate limited"), "expect tenant a to be limited")
+		_assert(!strings.Contains(sum("b"), "error"), "expect tenant b to have a bucket of its own")
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		cert := selfSignedCert(t, "alice")
+		l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}, ClientAuth: tls.RequireAnyClientCert})
+		_assert(err == nil, "listen error: %v", err)
+		defer func() { _ = l.Close() }()
+		go server.AcceptJSONRPC(l)
+
+		server.SetRateLimit("Foo.Sum", &RateLimit{Rate: 0.001, Burst: 1, By: CallerTLS})
+		defer server.SetRateLimit("Foo.Sum", nil)
+		conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{Certificates: []tls.Certificate{cert}, InsecureSkipVerify: true})
+		_assert(err == nil, "dial error: %v", err)
+		defer func() { _ = conn.Close() }()
+		r := bufio.NewReader(conn)
+		for i := 0; i < 2; i++ {
+			_, _ = conn.Write([]byte(`{"jsonrpc":"2.0","method":"Foo.Sum","params":{"Num1":1,"Num2":2},"id":1}`))
+			_, _ = r.ReadString('\n')
+		}
+		status := server.rateLimitStatus()["Foo.Sum"]
+		_assert(strings.Contains(status, "1 rejected"), "expect the second call to be limited, got %q", status)
+		server.rateLimiters["Foo.Sum"].mux.Lock()
+		_, ok := server.rateLimiters["Foo.Sum"].buckets["alice"]
+		server.rateLimiters["Foo.Sum"].mux.Unlock()
+		_assert(ok, "expect a bucket for the common name of the client certificate")
+	})
+}
+
+func selfSignedCert(t *testing.T, commonName string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_assert(err == nil, "generate key error: %v", err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	_assert(err == nil, "create certificate error: %v", err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
@@ -0,0 +1,156 @@
+package zrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ConcurrencyLimit caps the calls the server, a service or a method executes at once
+type ConcurrencyLimit struct {
+	MaxConcurrency int           // calls executing at once
+	MaxQueue       int           // calls waiting for a slot, 0 rejects calls as soon as all slots are taken
+	QueueTimeout   time.Duration // longest wait for a slot, 0 waits as long as the call's own timeout
+}
+
+const overloadedError = "rpc server: overloaded"
+
+// IsOverloaded reports whether err rejects a call because of a ConcurrencyLimit.
+// The server did not execute the call, so it is safe to retry elsewhere.
+func IsOverloaded(err error) bool {
+	var serverErr ServerError
+	return errors.As(err, &serverErr) && strings.HasPrefix(string(serverErr), overloadedError)
+}
+
+type limiter struct {
+	name     string
+	limit    ConcurrencyLimit
+	slots    chan struct{}
+	queued   int64
+	rejected uint64
+}
+
+func newLimiter(name string, limit *ConcurrencyLimit) *limiter {
+	return &limiter{
+		name:  name,
+		limit: *limit,
+		slots: make(chan struct{}, limit.MaxConcurrency),
+	}
+}
+
+// acquire takes a slot, waiting in the queue if the limit allows it
+func (l *limiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if atomic.AddInt64(&l.queued, 1) > int64(l.limit.MaxQueue) {
+		atomic.AddInt64(&l.queued, -1)
+		return l.overloaded()
+	}
+	defer atomic.AddInt64(&l.queued, -1)
+	var timeout <-chan time.Time
+	if l.limit.QueueTimeout > 0 {
+		t := time.NewTimer(l.limit.QueueTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		return l.overloaded()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// full reports whether acquire would reject right away
+func (l *limiter) full() bool {
+	return len(l.slots) == cap(l.slots) && atomic.LoadInt64(&l.queued) >= int64(l.limit.MaxQueue)
+}
+
+func (l *limiter) release() {
+	<-l.slots
+}
+
+func (l *limiter) overloaded() error {
+	atomic.AddUint64(&l.rejected, 1)
+	name := l.name
+	if name == "" {
+		name = "server"
+	}
+	return fmt.Errorf("%s: limit of %s reached, %d calls running and %d queued", overloadedError, name, l.limit.MaxConcurrency, l.limit.MaxQueue)
+}
+
+// SetConcurrencyLimit limits "Service.Method", "Service" for all of its methods
+// together, or "" for the whole server. A nil limit removes it again. Calls
+// already running or queued keep the limit they were admitted by.
+func (server *Server) SetConcurrencyLimit(name string, limit *ConcurrencyLimit) {
+	server.mux.Lock()
+	defer server.mux.Unlock()
+	if limit == nil || limit.MaxConcurrency <= 0 {
+		delete(server.limiters, name)
+		return
+	}
+	server.limiters[name] = newLimiter(name, limit)
+}
+
+// admit acquires the method, service and server limits in that order,
+// release has to be called once the call completes
+func (server *Server) admit(ctx context.Context, serviceName string, serviceMethod string) (release func(), err error) {
+	server.mux.Lock()
+	limiters := [...]*limiter{server.limiters[serviceMethod], server.limiters[serviceName], server.limiters[""]}
+	server.mux.Unlock()
+	acquired := make([]*limiter, 0, len(limiters))
+	release = func() {
+		for _, l := range acquired {
+			l.release()
+		}
+	}
+	for _, l := range limiters {
+		if l == nil {
+			continue
+		}
+		if err := l.acquire(ctx); err != nil {
+			release()
+			return nil, err
+		}
+		acquired = append(acquired, l)
+	}
+	return release, nil
+}
+
+// rejectFull rejects a call before its arguments are decoded and a goroutine
+// is started for it, if a limit has neither a free slot nor room in its queue.
+// Calls passing are still subject to admit.
+func (server *Server) rejectFull(serviceName string, serviceMethod string) error {
+	server.mux.Lock()
+	limiters := [...]*limiter{server.limiters[serviceMethod], server.limiters[serviceName], server.limiters[""]}
+	server.mux.Unlock()
+	for _, l := range limiters {
+		if l != nil && l.full() {
+			return l.overloaded()
+		}
+	}
+	return nil
+}
+
+// concurrencyStatus is shown on the debug page
+func (server *Server) concurrencyStatus() map[string]string {
+	server.mux.Lock()
+	defer server.mux.Unlock()
+	status := make(map[string]string, len(server.limiters))
+	for name, l := range server.limiters {
+		if name == "" {
+			name = "server"
+		}
+		status[name] = fmt.Sprintf("%d/%d running, %d/%d queued, %d rejected",
+			len(l.slots), l.limit.MaxConcurrency, atomic.LoadInt64(&l.queued), l.limit.MaxQueue, atomic.LoadUint64(&l.rejected))
+	}
+	return status
+}
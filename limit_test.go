@@ -0,0 +1,117 @@
+package zrpc
+
+import (
+	"context"
+	"github.com/vlzx/zrpc/codec"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type Slow struct{}
+
+func (s Slow) Sleep(d time.Duration, reply *int) error {
+	time.Sleep(d)
+	return nil
+}
+
+func (s Slow) Fast(n int, reply *int) error {
+	*reply = n
+	return nil
+}
+
+func TestServer_ConcurrencyLimit(t *testing.T) {
+	server := NewServer(&ServerOption{
+		MethodConcurrency: map[string]*ConcurrencyLimit{"Slow.Sleep": {MaxConcurrency: 2}},
+	})
+	_ = server.Register(&Slow{})
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go server.Accept(l)
+	defer server.Shutdown()
+	client, err := Dial("tcp", l.Addr().String())
+	_assert(err == nil, "dial error: %v", err)
+	defer func() { _ = client.Close() }()
+
+	burst := func(n int, d time.Duration) (overloaded int) {
+		var mux sync.Mutex
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := Invoke[time.Duration, int](context.Background(), client, "Slow.Sleep", d)
+				if IsOverloaded(err) {
+					mux.Lock()
+					overloaded++
+					mux.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		return
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		overloaded := burst(5, time.Millisecond*200)
+		_assert(overloaded == 3, "expect 3 calls rejected, got %d", overloaded)
+		// other methods are not limited
+		n, err := Invoke[int, int](context.Background(), client, "Slow.Fast", 1)
+		_assert(err == nil && n == 1, "expect Slow.Fast to succeed, got %v", err)
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		server.SetConcurrencyLimit("Slow.Sleep", &ConcurrencyLimit{MaxConcurrency: 2, MaxQueue: 3})
+		overloaded := burst(5, time.Millisecond*100)
+		_assert(overloaded == 0, "expect queued calls to complete, got %d rejected", overloaded)
+		server.SetConcurrencyLimit("Slow.Sleep", &ConcurrencyLimit{MaxConcurrency: 1, MaxQueue: 3, QueueTimeout: time.Millisecond * 50})
+		overloaded = burst(3, time.Millisecond*200)
+		_assert(overloaded == 2, "expect calls to leave the queue after QueueTimeout, got %d rejected", overloaded)
+	})
+
+	t.Run("server", func(t *testing.T) {
+		server.SetConcurrencyLimit("Slow.Sleep", nil)
+		server.SetConcurrencyLimit("", &ConcurrencyLimit{MaxConcurrency: 1})
+		overloaded := burst(3, time.Millisecond*200)
+		_assert(overloaded == 2, "expect 2 calls rejected, got %d", overloaded)
+		_assert(server.concurrencyStatus()["server"] != "", "expect the limit on the debug page")
+		server.SetConcurrencyLimit("", nil)
+		overloaded = burst(3, time.Millisecond*10)
+		_assert(overloaded == 0, "expect no limit, got %d rejected", overloaded)
+	})
+}
+
+func TestServer_ConcurrencyLimitBeforeDecoding(t *testing.T) {
+	server := NewServer(&ServerOption{
+		MethodConcurrency: map[string]*ConcurrencyLimit{"Slow.Sleep": {MaxConcurrency: 1}},
+	})
+	_ = server.Register(&Slow{})
+	conn, serverConn := net.Pipe()
+	go server.ServeCodec(codec.NewGobCodec(serverConn))
+	cc := codec.NewGobCodec(conn)
+	defer func() { _ = cc.Close() }()
+
+	// the pipe is unbuffered, responses are read while requests are written
+	errs := make(map[uint64]string)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			var header codec.Header
+			if cc.ReadHeader(&header) != nil {
+				return
+			}
+			_ = cc.ReadBody(nil)
+			errs[header.Seq] = header.Error
+		}
+	}()
+	_ = cc.Write(&codec.Header{ServiceMethod: "Slow.Sleep", Seq: 1}, time.Millisecond*200)
+	time.Sleep(time.Millisecond * 50)
+	// the argument does not even decode into a time.Duration
+	_ = cc.Write(&codec.Header{ServiceMethod: "Slow.Sleep", Seq: 2}, "not a duration")
+	_ = cc.Write(&codec.Header{ServiceMethod: "Slow.Fast", Seq: 3}, 7)
+	<-done
+	_assert(errs[1] == "", "expect the first call to succeed, got %q", errs[1])
+	_assert(IsOverloaded(ServerError(errs[2])), "expect the call rejected before decoding, got %q", errs[2])
+	_assert(errs[3] == "", "expect the stream to stay in sync, got %q", errs[3])
+}
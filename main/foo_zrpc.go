@@ -0,0 +1,44 @@
+// Code generated by zrpcgen -type Foo; DO NOT EDIT.
+
+package main
+
+import (
+	"context"
+	"github.com/vlzx/zrpc"
+)
+
+// FooClient is a typed client of the Foo service
+type FooClient struct {
+	c zrpc.Caller
+}
+
+// NewFooClient calls through c, e.g. a zrpc.Client or an xclient.XClient
+func NewFooClient(c zrpc.Caller) *FooClient {
+	return &FooClient{c: c}
+}
+
+func (c *FooClient) Sum(ctx context.Context, args Args) (int, error) {
+	return zrpc.Invoke[Args, int](ctx, c.c, "Foo.Sum", args)
+}
+
+func (c *FooClient) SumSleep(ctx context.Context, args Args) (int, error) {
+	return zrpc.Invoke[Args, int](ctx, c.c, "Foo.SumSleep", args)
+}
+
+func (c *FooClient) SumSlice(ctx context.Context, args []int) (int, error) {
+	return zrpc.Invoke[[]int, int](ctx, c.c, "Foo.SumSlice", args)
+}
+
+// FooServer is the interface of the Foo service
+type FooServer interface {
+	Sum(args Args, reply *int) error
+	SumSleep(args Args, reply *int) error
+	SumSlice(args []int, reply *int) error
+}
+
+var _ FooServer = (*Foo)(nil)
+
+// RegisterFooServer registers srv as the service Foo, whatever the type implementing it
+func RegisterFooServer(server *zrpc.Server, srv FooServer) error {
+	return server.RegisterName("Foo", srv)
+}
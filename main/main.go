@@ -12,6 +12,8 @@ import (
 	"time"
 )
 
+//go:generate go run github.com/vlzx/zrpc/cmd/zrpcgen -type Foo
+
 type Foo struct{}
 
 type Args struct {
@@ -56,7 +58,7 @@ func startServer(registryAddr string, wg *sync.WaitGroup) {
 	server := zrpc.NewServer()
 	//handler := server.HandleHTTP() // HTTP
 
-	err = server.Register(&foo)
+	err = RegisterFooServer(server, &foo)
 	if err != nil {
 		log.Fatal("register error:", err)
 	}
@@ -128,12 +130,19 @@ func xCall(registryAddr string) {
 	d := xclient.NewRegistryDiscovery(registryAddr, 0)
 	xc := xclient.NewXClient(d, xclient.RandomSelect, nil)
 	defer func() { _ = xc.Close() }()
+	foo := NewFooClient(xc)
 	var wg sync.WaitGroup
 	for i := 0; i < 5; i++ {
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			afterLog(xc, "call", "Foo.Sum", &Args{Num1: i, Num2: i * i}, context.Background())
+			args := Args{Num1: i, Num2: i * i}
+			reply, err := foo.Sum(context.Background(), args)
+			if err != nil {
+				log.Printf("call Foo.Sum error: %s", err)
+			} else {
+				log.Printf("call Foo.Sum success: %d + %d = %d", args.Num1, args.Num2, reply)
+			}
 		}(i)
 	}
 	wg.Wait()
@@ -149,7 +158,8 @@ func xBroadcast(registryAddr string) {
 		go func(i int) {
 			defer wg.Done()
 			afterLog(xc, "broadcast", "Foo.Sum", &Args{Num1: i, Num2: i * i}, context.Background())
-			ctx, _ := context.WithTimeout(context.Background(), time.Second*2)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+			defer cancel()
 			afterLog(xc, "broadcast", "Foo.SumSleep", &Args{Num1: i, Num2: i * i}, ctx)
 		}(i)
 	}
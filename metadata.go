@@ -0,0 +1,25 @@
+package zrpc
+
+import "context"
+
+type metadataKey struct{}
+
+// WithMetadata adds key/value pairs to the calls made with ctx, they are sent
+// in the request header, e.g. to identify the caller for a RateLimit
+func WithMetadata(ctx context.Context, md map[string]string) context.Context {
+	merged := make(map[string]string)
+	for k, v := range MetadataFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range md {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, metadataKey{}, merged)
+}
+
+// MetadataFromContext returns the metadata added by WithMetadata, on the server
+// side the metadata of the call a handler of HandleFunc is serving
+func MetadataFromContext(ctx context.Context) map[string]string {
+	md, _ := ctx.Value(metadataKey{}).(map[string]string)
+	return md
+}
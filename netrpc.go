@@ -0,0 +1,119 @@
+package zrpc
+
+import (
+	"bufio"
+	"github.com/vlzx/zrpc/codec"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+)
+
+// netRPCConnected is the status line net/rpc.DialHTTP expects after CONNECT
+const netRPCConnected = "200 Connected to Go RPC"
+
+// netRPCCodec adapts a net/rpc server codec to codec.Codec, so that
+// requests from stock net/rpc clients go through ServeCodec as well
+type netRPCCodec struct {
+	sc rpc.ServerCodec
+}
+
+var _ codec.Codec = (*netRPCCodec)(nil)
+
+func newNetRPCCodec(sc rpc.ServerCodec) codec.Codec {
+	return &netRPCCodec{sc: sc}
+}
+
+func (c *netRPCCodec) ReadHeader(header *codec.Header) error {
+	var req rpc.Request
+	if err := c.sc.ReadRequestHeader(&req); err != nil {
+		return err
+	}
+	header.ServiceMethod = req.ServiceMethod
+	header.Seq = req.Seq
+	return nil
+}
+
+func (c *netRPCCodec) ReadBody(body interface{}) error {
+	return c.sc.ReadRequestBody(body)
+}
+
+func (c *netRPCCodec) Write(header *codec.Header, body interface{}) error {
+	resp := &rpc.Response{
+		ServiceMethod: header.ServiceMethod,
+		Seq:           header.Seq,
+		Error:         header.Error,
+	}
+	return c.sc.WriteResponse(resp, body)
+}
+
+func (c *netRPCCodec) Close() error {
+	return c.sc.Close()
+}
+
+type bufferedConn struct {
+	*bufio.Reader
+	io.ReadWriteCloser
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.Reader.Read(p)
+}
+
+// ServeNetRPCConn serves a connection from a stock net/rpc client. The first
+// byte tells the variants apart: jsonrpc starts with a JSON object, while gob
+// starts with a message length. No option block is exchanged.
+func (server *Server) ServeNetRPCConn(conn io.ReadWriteCloser) {
+	r := bufio.NewReader(conn)
+	first, err := r.Peek(1)
+	if err != nil {
+		if err != io.EOF {
+			log.Println("rpc server: net/rpc peek error:", err)
+		}
+		_ = conn.Close()
+		return
+	}
+	caller := newCaller(conn)
+	conn = &bufferedConn{Reader: r, ReadWriteCloser: conn}
+	switch first[0] {
+	case '{', ' ', '\t', '\r', '\n':
+		server.serveCodec(newNetRPCCodec(jsonrpc.NewServerCodec(conn)), caller)
+	default:
+		// codec.Header shares the gob field names of rpc.Request and rpc.Response,
+		// so the zRPC gob codec already speaks the net/rpc gob wire format
+		server.serveCodec(codec.NewGobCodec(conn), caller)
+	}
+}
+
+// AcceptNetRPC accepts connections from net/rpc clients, using either
+// rpc.Dial (gob) or jsonrpc.Dial, and serves them from the same Server
+func (server *Server) AcceptNetRPC(listener net.Listener) {
+	server.acceptLoop(listener, server.ServeNetRPCConn)
+}
+
+func AcceptNetRPC(listener net.Listener) {
+	DefaultServer.AcceptNetRPC(listener)
+}
+
+// netRPCHTTP serves net/rpc clients connected by rpc.DialHTTP
+type netRPCHTTP struct {
+	*Server
+}
+
+func (server netRPCHTTP) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "CONNECT" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = io.WriteString(w, "405 must CONNECT\n")
+		return
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		log.Println("rpc hijacking ", req.RemoteAddr, ": ", err.Error())
+		return
+	}
+	_, _ = io.WriteString(conn, "HTTP/1.0 "+netRPCConnected+"\n\n")
+	server.serveCodec(codec.NewGobCodec(conn), newCaller(conn))
+}
@@ -0,0 +1,36 @@
+package zrpc
+
+import (
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strings"
+	"testing"
+)
+
+func TestServer_AcceptNetRPC(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(&Foo{})
+	l, _ := net.Listen("tcp", ":0")
+	defer func() { _ = l.Close() }()
+	go server.AcceptNetRPC(l)
+
+	dials := map[string]func(network, address string) (*rpc.Client, error){
+		"gob":     rpc.Dial,
+		"jsonrpc": jsonrpc.Dial,
+	}
+	for name, dial := range dials {
+		t.Run(name, func(t *testing.T) {
+			client, err := dial("tcp", l.Addr().String())
+			_assert(err == nil, "dial error: %v", err)
+			defer func() { _ = client.Close() }()
+			var reply int
+			err = client.Call("Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+			_assert(err == nil && reply == 3, "failed to call Foo.Sum: %v", err)
+			err = client.Call("Foo.Nope", Args{}, &reply)
+			_assert(err != nil && strings.Contains(err.Error(), "can not find method"), "expect a method error")
+			err = client.Call("Foo.Multiply", Args{Num1: 2, Num2: 3}, &reply)
+			_assert(err == nil && reply == 6, "failed to call Foo.Multiply after an error: %v", err)
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package zrpc
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CallerKey tells the callers of a RateLimit apart, each one gets a bucket of its own
+type CallerKey string
+
+const (
+	AllCallers CallerKey = ""     // one bucket shared by all callers
+	CallerAddr CallerKey = "addr" // the remote IP address
+	CallerTLS  CallerKey = "tls"  // the common name of the client certificate
+)
+
+// CallerMetadata identifies callers by the metadata value of key, see WithMetadata.
+// JSON-RPC requests carry metadata in a "metadata" member next to "params".
+func CallerMetadata(key string) CallerKey {
+	return CallerKey("metadata:" + key)
+}
+
+// RateLimit is a token bucket, refilled at Rate tokens per second up to Burst
+type RateLimit struct {
+	Rate  float64
+	Burst int // at least 1
+	By    CallerKey
+}
+
+const rateLimitedError = "rpc server: rate limited"
+
+// IsRateLimited reports whether err rejects a call because of a RateLimit,
+// the server did not execute the call
+func IsRateLimited(err error) bool {
+	var serverErr ServerError
+	return errors.As(err, &serverErr) && strings.HasPrefix(string(serverErr), rateLimitedError)
+}
+
+// caller is the connection a request came from
+type caller struct {
+	addr    string
+	tlsPeer string
+}
+
+func newCaller(conn io.ReadWriteCloser) *caller {
+	c := new(caller)
+	if nc, ok := conn.(net.Conn); ok && nc.RemoteAddr() != nil {
+		c.addr = hostOf(nc.RemoteAddr().String())
+	}
+	// the handshake is done once the first bytes are read
+	if tc, ok := conn.(*tls.Conn); ok {
+		if certs := tc.ConnectionState().PeerCertificates; len(certs) > 0 {
+			c.tlsPeer = certs[0].Subject.CommonName
+		}
+	}
+	return c
+}
+
+func newHTTPCaller(req *http.Request) *caller {
+	c := &caller{addr: hostOf(req.RemoteAddr)}
+	if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+		c.tlsPeer = req.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	return c
+}
+
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// key returns the bucket of a call, callers that can not be identified share one
+func (c *caller) key(by CallerKey, metadata map[string]string) string {
+	switch {
+	case by == AllCallers:
+		return ""
+	case by == CallerAddr && c != nil:
+		return c.addr
+	case by == CallerTLS && c != nil:
+		return c.tlsPeer
+	case strings.HasPrefix(string(by), "metadata:"):
+		return metadata[strings.TrimPrefix(string(by), "metadata:")]
+	}
+	return ""
+}
+
+const maxIdleBuckets = 1024
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	name     string
+	limit    RateLimit
+	mux      sync.Mutex // protect following
+	buckets  map[string]*bucket
+	rejected uint64
+}
+
+func newRateLimiter(name string, limit *RateLimit) *rateLimiter {
+	l := &rateLimiter{name: name, limit: *limit, buckets: make(map[string]*bucket)}
+	if l.limit.Burst < 1 {
+		l.limit.Burst = 1
+	}
+	return l
+}
+
+// get returns the refilled bucket of key, l.mux is held
+func (l *rateLimiter) get(key string, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.prune(now)
+		}
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.refill(l.limit, now)
+	return b
+}
+
+func (l *rateLimiter) reject(key string) error {
+	l.rejected++
+	name := l.name
+	if name == "" {
+		name = "server"
+	}
+	if key != "" {
+		name += " for " + key
+	}
+	return fmt.Errorf("%s: limit of %s reached, %g calls per second", rateLimitedError, name, l.limit.Rate)
+}
+
+func (b *bucket) refill(limit RateLimit, now time.Time) {
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+}
+
+// prune forgets full buckets, a new bucket starts full anyway. If callers are
+// still over maxIdleBuckets, the least recently used half is forgotten too.
+func (l *rateLimiter) prune(now time.Time) {
+	keys := make([]string, 0, len(l.buckets))
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) < maxIdleBuckets {
+		return
+	}
+	sort.Slice(keys, func(i, j int) bool { return l.buckets[keys[i]].last.Before(l.buckets[keys[j]].last) })
+	for _, key := range keys[:len(keys)-maxIdleBuckets/2] {
+		delete(l.buckets, key)
+	}
+}
+
+// SetRateLimit limits "Service.Method", "Service" for all of its methods
+// together, or "" for the whole server. A nil limit removes it again.
+func (server *Server) SetRateLimit(name string, limit *RateLimit) {
+	server.mux.Lock()
+	defer server.mux.Unlock()
+	if limit == nil {
+		delete(server.rateLimiters, name)
+		return
+	}
+	server.rateLimiters[name] = newRateLimiter(name, limit)
+}
+
+// allowRate checks the method, service and server rate limits, a token is
+// taken from each of them only if none rejects the call
+func (server *Server) allowRate(c *caller, serviceName string, serviceMethod string, metadata map[string]string) error {
+	server.mux.Lock()
+	if len(server.rateLimiters) == 0 {
+		server.mux.Unlock()
+		return nil
+	}
+	limiters := [...]*rateLimiter{server.rateLimiters[serviceMethod], server.rateLimiters[serviceName], server.rateLimiters[""]}
+	server.mux.Unlock()
+	now := time.Now()
+	var buckets [len(limiters)]*bucket
+	// always locked in the same order
+	for i, l := range limiters {
+		if l == nil {
+			continue
+		}
+		l.mux.Lock()
+		defer l.mux.Unlock()
+		key := c.key(l.limit.By, metadata)
+		buckets[i] = l.get(key, now)
+		if buckets[i].tokens < 1 {
+			return l.reject(key)
+		}
+	}
+	for _, b := range buckets {
+		if b != nil {
+			b.tokens--
+		}
+	}
+	return nil
+}
+
+// rateLimitStatus is shown on the debug page
+func (server *Server) rateLimitStatus() map[string]string {
+	server.mux.Lock()
+	defer server.mux.Unlock()
+	status := make(map[string]string, len(server.rateLimiters))
+	for name, l := range server.rateLimiters {
+		if name == "" {
+			name = "server"
+		}
+		l.mux.Lock()
+		by := "all callers"
+		if l.limit.By != AllCallers {
+			by = fmt.Sprintf("per %s, %d callers", l.limit.By, len(l.buckets))
+		}
+		status[name] = fmt.Sprintf("%g/s, burst %d, %s, %d rejected", l.limit.Rate, l.limit.Burst, by, l.rejected)
+		l.mux.Unlock()
+	}
+	return status
+}
@@ -0,0 +1,98 @@
+package zrpc
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_RateLimit(t *testing.T) {
+	server := NewServer(&ServerOption{
+		RateLimits: map[string]*RateLimit{"Foo.Sum": {Rate: 1, Burst: 2}},
+	})
+	_ = server.Register(&Foo{})
+	_ = server.HandleFunc("Meta.Get", func(ctx context.Context, key string, value *string) error {
+		*value = MetadataFromContext(ctx)[key]
+		return nil
+	})
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go server.Accept(l)
+	defer server.Shutdown()
+	client, err := Dial("tcp", l.Addr().String())
+	_assert(err == nil, "dial error: %v", err)
+	defer func() { _ = client.Close() }()
+
+	sum := func(ctx context.Context) error {
+		_, err := Invoke[Args, int](ctx, client, "Foo.Sum", Args{Num1: 1, Num2: 2})
+		return err
+	}
+
+	t.Run("method", func(t *testing.T) {
+		_assert(sum(context.Background()) == nil && sum(context.Background()) == nil, "expect the burst to pass")
+		err := sum(context.Background())
+		_assert(IsRateLimited(err), "expect a rate limit error, got %v", err)
+		// the rejected body was discarded, the connection is still in sync
+		product, err := Invoke[Args, int](context.Background(), client, "Foo.Multiply", Args{Num1: 2, Num2: 3})
+		_assert(err == nil && product == 6, "expect 6, got %d %v", product, err)
+		status := server.rateLimitStatus()["Foo.Sum"]
+		_assert(strings.Contains(status, "1 rejected"), "unexpected debug status %q", status)
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		ctx := WithMetadata(context.Background(), map[string]string{"tenant": "a"})
+		value, err := Invoke[string, string](ctx, client, "Meta.Get", "tenant")
+		_assert(err == nil && value == "a", "expect the handler to see the metadata, got %q %v", value, err)
+
+		server.SetRateLimit("Foo.Sum", &RateLimit{Rate: 1, Burst: 1, By: CallerMetadata("tenant")})
+		_assert(sum(ctx) == nil, "expect the first call of tenant a to pass")
+		_assert(IsRateLimited(sum(ctx)), "expect tenant a to be limited")
+		ctxB := WithMetadata(ctx, map[string]string{"tenant": "b"})
+		_assert(sum(ctxB) == nil, "expect tenant b to have a bucket of its own")
+	})
+
+	t.Run("runtime", func(t *testing.T) {
+		server.SetRateLimit("Foo.Sum", &RateLimit{Rate: 50, Burst: 1, By: CallerAddr})
+		_assert(sum(context.Background()) == nil, "expect the first call to pass")
+		_assert(IsRateLimited(sum(context.Background())), "expect the second call to be limited")
+		time.Sleep(time.Millisecond * 30)
+		_assert(sum(context.Background()) == nil, "expect the bucket to refill")
+		_assert(strings.Contains(server.rateLimitStatus()["Foo.Sum"], "per addr, 1 callers"), "expect the caller on the debug page")
+		server.SetRateLimit("Foo.Sum", nil)
+		for i := 0; i < 5; i++ {
+			_assert(sum(context.Background()) == nil, "expect no limit")
+		}
+	})
+}
+
+func TestServer_AllowRate(t *testing.T) {
+	server := NewServer()
+	server.SetRateLimit("Foo.Sum", &RateLimit{Rate: 0.001, Burst: 2})
+	server.SetRateLimit("", &RateLimit{Rate: 0.001, Burst: 1, By: CallerMetadata("tenant")})
+	a, b := map[string]string{"tenant": "a"}, map[string]string{"tenant": "b"}
+	_assert(server.allowRate(nil, "Foo", "Foo.Sum", a) == nil, "expect the first call to pass")
+	for i := 0; i < 3; i++ {
+		err := server.allowRate(nil, "Foo", "Foo.Sum", a)
+		_assert(IsRateLimited(ServerError(err.Error())), "expect the server limit to reject, got %v", err)
+	}
+	// the rejected calls did not take a token of Foo.Sum
+	_assert(server.allowRate(nil, "Foo", "Foo.Sum", b) == nil, "expect a token of Foo.Sum left")
+	_assert(server.allowRate(nil, "Foo", "Foo.Sum", map[string]string{"tenant": "c"}) != nil, "expect Foo.Sum to be used up")
+}
+
+func TestRateLimiter_Prune(t *testing.T) {
+	l := newRateLimiter("Foo.Sum", &RateLimit{Rate: 0.001, Burst: 1, By: CallerMetadata("tenant")})
+	now := time.Now()
+	for i := 0; i < maxIdleBuckets*3; i++ {
+		now = now.Add(time.Millisecond)
+		_ = l.get(strconv.Itoa(i), now)
+		l.buckets[strconv.Itoa(i)].tokens-- // none of them is full
+	}
+	_assert(len(l.buckets) <= maxIdleBuckets, "expect at most %d buckets, got %d", maxIdleBuckets, len(l.buckets))
+	_, ok := l.buckets[strconv.Itoa(maxIdleBuckets*3-1)]
+	_assert(ok, "expect the most recent caller to be kept")
+	_, ok = l.buckets["0"]
+	_assert(!ok, "expect the least recent caller to be forgotten")
+}
@@ -0,0 +1,228 @@
+package zrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type ConnState int
+
+const (
+	Connecting ConnState = iota
+	Ready
+	TransientFailure
+	Shutdown
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case Connecting:
+		return "CONNECTING"
+	case Ready:
+		return "READY"
+	case TransientFailure:
+		return "TRANSIENT_FAILURE"
+	case Shutdown:
+		return "SHUTDOWN"
+	default:
+		return "INVALID_STATE"
+	}
+}
+
+var ErrNotReady = errors.New("rpc client: connection is not ready")
+
+type ReconnectOption struct {
+	BaseDelay     time.Duration   // backoff after the first failure
+	MaxDelay      time.Duration   // upper bound of the backoff
+	Multiplier    float64         // factor the backoff grows by after each failure
+	Jitter        float64         // randomize the backoff by +/- this fraction
+	QueueCalls    bool            // wait for the connection instead of failing with ErrNotReady
+	OnStateChange func(ConnState) // called on every state transition
+}
+
+var DefaultReconnectOption = &ReconnectOption{
+	BaseDelay:  time.Second,
+	MaxDelay:   time.Second * 30,
+	Multiplier: 1.6,
+	Jitter:     0.2,
+}
+
+// ReconnectClient wraps Client and dials again, including the option
+// handshake, whenever the connection is lost
+type ReconnectClient struct {
+	rpcAddr string
+	opt     *Option
+	ropt    *ReconnectOption
+	mux     sync.Mutex // protect following
+	r       *rand.Rand
+	client  *Client
+	state   ConnState
+	changed chan struct{} // closed and replaced on every state transition
+	closed  chan struct{}
+}
+
+var _ io.Closer = (*ReconnectClient)(nil)
+
+// DialReconnect returns immediately and connects to rpcAddr (protocol@addr,
+// as accepted by XDial) in the background. Fields of ropt left at zero take
+// the value of DefaultReconnectOption.
+func DialReconnect(rpcAddr string, ropt *ReconnectOption, opts ...*Option) (*ReconnectClient, error) {
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if ropt == nil {
+		ropt = DefaultReconnectOption
+	}
+	copied := *ropt
+	if copied.BaseDelay <= 0 {
+		copied.BaseDelay = DefaultReconnectOption.BaseDelay
+	}
+	if copied.MaxDelay <= 0 {
+		copied.MaxDelay = DefaultReconnectOption.MaxDelay
+	}
+	if copied.Multiplier <= 0 {
+		copied.Multiplier = DefaultReconnectOption.Multiplier
+	}
+	rc := &ReconnectClient{
+		rpcAddr: rpcAddr,
+		opt:     opt,
+		ropt:    &copied,
+		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		state:   Connecting,
+		changed: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	go rc.run()
+	return rc, nil
+}
+
+func (rc *ReconnectClient) run() {
+	for attempt := 0; ; attempt++ {
+		if !rc.setState(Connecting, nil) {
+			return
+		}
+		client, err := XDial(rc.rpcAddr, rc.opt)
+		if err != nil {
+			log.Println("rpc client: reconnect error:", err)
+			rc.setState(TransientFailure, nil)
+			select {
+			case <-time.After(rc.backoff(attempt)):
+				continue
+			case <-rc.closed:
+				return
+			}
+		}
+		if !rc.setState(Ready, client) {
+			_ = client.Close()
+			return
+		}
+		attempt = -1
+		select {
+		case <-client.done:
+			_ = client.Close()
+			rc.setState(TransientFailure, nil)
+		case <-rc.closed:
+			return
+		}
+	}
+}
+
+func (rc *ReconnectClient) backoff(attempt int) time.Duration {
+	delay := float64(rc.ropt.BaseDelay) * math.Pow(rc.ropt.Multiplier, float64(attempt))
+	// math.Pow grows to +Inf, not past it
+	delay = math.Min(delay, float64(rc.ropt.MaxDelay))
+	rc.mux.Lock()
+	delay *= 1 + rc.ropt.Jitter*(rc.r.Float64()*2-1)
+	rc.mux.Unlock()
+	return time.Duration(delay)
+}
+
+// setState reports false if the client has been closed meanwhile
+func (rc *ReconnectClient) setState(state ConnState, client *Client) bool {
+	rc.mux.Lock()
+	if rc.state == Shutdown {
+		rc.mux.Unlock()
+		return false
+	}
+	changed := rc.state != state
+	rc.state = state
+	rc.client = client
+	if changed {
+		close(rc.changed)
+		rc.changed = make(chan struct{})
+	}
+	rc.mux.Unlock()
+	if changed && rc.ropt.OnStateChange != nil {
+		rc.ropt.OnStateChange(state)
+	}
+	return true
+}
+
+func (rc *ReconnectClient) State() ConnState {
+	rc.mux.Lock()
+	defer rc.mux.Unlock()
+	return rc.state
+}
+
+// getClient returns the current connection, waiting for it if calls are queued
+func (rc *ReconnectClient) getClient(ctx context.Context) (*Client, error) {
+	for {
+		rc.mux.Lock()
+		state, client, changed := rc.state, rc.client, rc.changed
+		rc.mux.Unlock()
+		switch {
+		case state == Shutdown:
+			return nil, ErrShutdown
+		case state == Ready && client.IsAvailable():
+			return client, nil
+		case !rc.ropt.QueueCalls:
+			return nil, ErrNotReady
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("rpc client: call timeout: %w", ctx.Err())
+		}
+	}
+}
+
+func (rc *ReconnectClient) Call(serviceMethod string, args interface{}, reply interface{}, ctx ...context.Context) error {
+	defaultCtx := context.Background()
+	if len(ctx) == 1 && ctx[0] != nil {
+		defaultCtx = ctx[0]
+	}
+	client, err := rc.getClient(defaultCtx)
+	if err != nil {
+		return err
+	}
+	return client.Call(serviceMethod, args, reply, ctx...)
+}
+
+func (rc *ReconnectClient) Close() error {
+	rc.mux.Lock()
+	if rc.state == Shutdown {
+		rc.mux.Unlock()
+		return ErrShutdown
+	}
+	client := rc.client
+	rc.state = Shutdown
+	rc.client = nil
+	close(rc.changed)
+	close(rc.closed)
+	rc.mux.Unlock()
+	if rc.ropt.OnStateChange != nil {
+		rc.ropt.OnStateChange(Shutdown)
+	}
+	if client != nil {
+		return client.Close()
+	}
+	return nil
+}
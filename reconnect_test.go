@@ -0,0 +1,111 @@
+package zrpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// connListener remembers accepted connections so that a test can drop them
+type connListener struct {
+	net.Listener
+	mux   sync.Mutex
+	conns []net.Conn
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mux.Lock()
+		l.conns = append(l.conns, conn)
+		l.mux.Unlock()
+	}
+	return conn, err
+}
+
+func (l *connListener) dropAll() {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	for _, conn := range l.conns {
+		_ = conn.Close()
+	}
+	l.conns = nil
+}
+
+func TestReconnectClient(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(&Foo{})
+	// reserve an address, the server starts listening on it later
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	var mux sync.Mutex
+	var states []ConnState
+	rc, err := DialReconnect("tcp@"+addr, &ReconnectOption{
+		BaseDelay:  time.Millisecond * 50,
+		MaxDelay:   time.Millisecond * 200,
+		Multiplier: 2,
+		Jitter:     0.1,
+		QueueCalls: true,
+		OnStateChange: func(state ConnState) {
+			mux.Lock()
+			states = append(states, state)
+			mux.Unlock()
+		},
+	})
+	_assert(err == nil, "dial error: %v", err)
+	defer func() { _ = rc.Close() }()
+
+	time.Sleep(time.Millisecond * 100)
+	_assert(rc.State() != Ready, "expect not ready before the server starts")
+	l, err = net.Listen("tcp", addr)
+	_assert(err == nil, "listen error: %v", err)
+	cl := &connListener{Listener: l}
+	defer func() { _ = cl.Close() }()
+	go server.Accept(cl)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	var reply int
+	err = rc.Call("Foo.Sum", Args{Num1: 1, Num2: 2}, &reply, ctx)
+	_assert(err == nil && reply == 3, "expect the queued call to succeed: %v", err)
+
+	cl.dropAll()
+	time.Sleep(time.Millisecond * 100) // calls in flight while the connection drops still fail
+	err = rc.Call("Foo.Sum", Args{Num1: 2, Num2: 3}, &reply, ctx)
+	_assert(err == nil && reply == 5, "expect the call to succeed after reconnecting: %v", err)
+
+	mux.Lock()
+	defer mux.Unlock()
+	failed := false
+	for _, state := range states {
+		failed = failed || state == TransientFailure
+	}
+	_assert(failed && states[len(states)-1] == Ready, "unexpected state transitions %v", states)
+}
+
+func TestReconnectClient_Backoff(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	_ = l.Close()
+	rc, err := DialReconnect("tcp@"+addr, &ReconnectOption{QueueCalls: true})
+	_assert(err == nil, "dial error: %v", err)
+	defer func() { _ = rc.Close() }()
+
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, DefaultReconnectOption.BaseDelay},
+		{1, time.Duration(float64(DefaultReconnectOption.BaseDelay) * DefaultReconnectOption.Multiplier)},
+		{100, DefaultReconnectOption.MaxDelay},
+		{10000, DefaultReconnectOption.MaxDelay},
+	}
+	for _, c := range cases {
+		got := rc.backoff(c.attempt)
+		_assert(got == c.want, "attempt %d: expect a backoff of %s, got %s", c.attempt, c.want, got)
+	}
+}
@@ -0,0 +1,114 @@
+package zrpc
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+)
+
+// TypeDesc is a machine-readable schema of a Go type
+type TypeDesc struct {
+	Name   string      // e.g. "main.Args", "" for unnamed types
+	Kind   string      // reflect.Kind, e.g. "struct", "slice", "int"
+	Ref    string      // Name of an enclosing type this one refers back to, nothing else is set then
+	Elem   *TypeDesc   // of pointers, slices, arrays, maps and channels
+	Key    *TypeDesc   // of maps
+	Len    int         // of arrays
+	Fields []FieldDesc // exported fields of structs
+}
+
+type FieldDesc struct {
+	Name string
+	Type *TypeDesc
+	Tag  string
+}
+
+type MethodDesc struct {
+	Name      string
+	ArgType   *TypeDesc
+	ReplyType *TypeDesc
+}
+
+type ServiceDesc struct {
+	Name    string
+	Methods []MethodDesc
+}
+
+// Reflection is the built-in service "zrpc.Reflection" describing every service
+// of a Server, for tools that discover APIs at runtime
+type Reflection struct {
+	server *Server
+}
+
+// ListServices returns the sorted names of all registered services, the argument is ignored
+func (r *Reflection) ListServices(_ string, services *[]string) error {
+	r.server.serviceMap.Range(func(namei, _ interface{}) bool {
+		*services = append(*services, namei.(string))
+		return true
+	})
+	sort.Strings(*services)
+	return nil
+}
+
+// Describe returns the methods of the service with the schemas of their argument and reply types
+func (r *Reflection) Describe(name string, desc *ServiceDesc) error {
+	svci, ok := r.server.serviceMap.Load(name)
+	if !ok {
+		return errors.New("rpc reflection: can not find service " + name)
+	}
+	*desc = describeService(svci.(*service))
+	return nil
+}
+
+func describeService(svc *service) ServiceDesc {
+	desc := ServiceDesc{Name: svc.name}
+	for name, mType := range svc.method {
+		desc.Methods = append(desc.Methods, MethodDesc{
+			Name:      name,
+			ArgType:   describeType(mType.ArgType),
+			ReplyType: describeType(mType.ReplyType),
+		})
+	}
+	sort.Slice(desc.Methods, func(i, j int) bool { return desc.Methods[i].Name < desc.Methods[j].Name })
+	return desc
+}
+
+func describeType(t reflect.Type) *TypeDesc {
+	return describe(t, make(map[reflect.Type]bool))
+}
+
+// describe refers back by Ref to named types enclosing t, so recursive types terminate
+func describe(t reflect.Type, enclosing map[reflect.Type]bool) *TypeDesc {
+	desc := &TypeDesc{Kind: t.Kind().String()}
+	if t.Name() != "" {
+		desc.Name = t.String()
+		if enclosing[t] {
+			return &TypeDesc{Ref: desc.Name}
+		}
+		enclosing[t] = true
+		defer delete(enclosing, t)
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Chan:
+		desc.Elem = describe(t.Elem(), enclosing)
+	case reflect.Array:
+		desc.Elem = describe(t.Elem(), enclosing)
+		desc.Len = t.Len()
+	case reflect.Map:
+		desc.Key = describe(t.Key(), enclosing)
+		desc.Elem = describe(t.Elem(), enclosing)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue // unexported, not sent by the codecs
+			}
+			desc.Fields = append(desc.Fields, FieldDesc{
+				Name: field.Name,
+				Type: describe(field.Type, enclosing),
+				Tag:  string(field.Tag),
+			})
+		}
+	}
+	return desc
+}
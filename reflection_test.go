@@ -0,0 +1,60 @@
+package zrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+type Node struct {
+	Value    int
+	Children []*Node
+	Attrs    map[string]Args
+	parent   *Node
+}
+
+type Tree struct{}
+
+func (t Tree) Walk(root *Node, count *int) error {
+	return nil
+}
+
+func TestReflection(t *testing.T) {
+	server := NewServer()
+	_ = server.Register(&Foo{})
+	_ = server.Register(&Tree{})
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go server.Accept(l)
+	defer server.Shutdown()
+
+	client, err := Dial("tcp", l.Addr().String())
+	_assert(err == nil, "dial error: %v", err)
+	defer func() { _ = client.Close() }()
+
+	var services []string
+	err = client.Call("zrpc.Reflection.ListServices", "", &services, context.Background())
+	_assert(err == nil, "list error: %v", err)
+	_assert(len(services) == 4 && services[0] == "Foo" && services[1] == "Tree" && services[3] == "zrpc.Reflection", "unexpected services %v", services)
+
+	var desc ServiceDesc
+	err = client.Call("zrpc.Reflection.Describe", "Foo", &desc, context.Background())
+	_assert(err == nil, "describe error: %v", err)
+	_assert(len(desc.Methods) == 2 && desc.Methods[0].Name == "Multiply", "unexpected methods %+v", desc.Methods)
+	arg := desc.Methods[1].ArgType
+	_assert(arg.Name == "zrpc.Args" && arg.Kind == "struct" && len(arg.Fields) == 2, "unexpected arg type %+v", arg)
+	_assert(arg.Fields[0].Name == "Num1" && arg.Fields[0].Type.Kind == "int", "unexpected field %+v", arg.Fields[0])
+	reply := desc.Methods[1].ReplyType
+	_assert(reply.Kind == "ptr" && reply.Elem.Kind == "int", "unexpected reply type %+v", reply)
+
+	err = client.Call("zrpc.Reflection.Describe", "Tree", &desc, context.Background())
+	_assert(err == nil, "describe error: %v", err)
+	node := desc.Methods[0].ArgType.Elem
+	_assert(node.Name == "zrpc.Node" && len(node.Fields) == 3, "unexpected node type %+v", node)
+	children := node.Fields[1].Type
+	_assert(children.Kind == "slice" && children.Elem.Elem.Ref == "zrpc.Node", "expect a reference back to Node, got %+v", children.Elem.Elem)
+	attrs := node.Fields[2].Type
+	_assert(attrs.Key.Kind == "string" && attrs.Elem.Name == "zrpc.Args", "unexpected map type %+v", attrs)
+
+	err = client.Call("zrpc.Reflection.Describe", "Unknown", &desc, context.Background())
+	_assert(err != nil, "expect an error for an unknown service")
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,8 +17,9 @@ type Registry struct {
 }
 
 type ServerNode struct {
-	Addr  string
-	start time.Time
+	Addr   string
+	Weight int // 0 if the server did not report one
+	start  time.Time
 }
 
 const (
@@ -34,36 +36,45 @@ func NewRegistry(timeout time.Duration) *Registry {
 
 var DefaultRegistry = NewRegistry(defaultTimeout)
 
-func (r *Registry) putServer(addr string) {
+func (r *Registry) putServer(addr string, weight int) {
 	r.mux.Lock()
 	defer r.mux.Unlock()
 	s := r.servers[addr]
 	if s == nil {
-		r.servers[addr] = &ServerNode{Addr: addr, start: time.Now()}
+		r.servers[addr] = &ServerNode{Addr: addr, Weight: weight, start: time.Now()}
 	} else {
+		s.Weight = weight
 		s.start = time.Now()
 	}
 }
 
-func (r *Registry) aliveServers() []string {
+func (r *Registry) aliveServers() []*ServerNode {
 	r.mux.Lock()
 	defer r.mux.Unlock()
-	var alive []string
+	var alive []*ServerNode
 	for addr, s := range r.servers {
 		if r.timeout == 0 || s.start.Add(r.timeout).After(time.Now()) {
-			alive = append(alive, addr)
+			alive = append(alive, &ServerNode{Addr: s.Addr, Weight: s.Weight})
 		} else {
 			delete(r.servers, addr)
 		}
 	}
-	sort.Strings(alive)
+	sort.Slice(alive, func(i, j int) bool { return alive[i].Addr < alive[j].Addr })
 	return alive
 }
 
 func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case "GET":
-		w.Header().Set("X-Zrpc-Servers", strings.Join(r.aliveServers(), ","))
+		alive := r.aliveServers()
+		servers := make([]string, 0, len(alive))
+		weights := make([]string, 0, len(alive))
+		for _, s := range alive {
+			servers = append(servers, s.Addr)
+			weights = append(weights, strconv.Itoa(s.Weight))
+		}
+		w.Header().Set("X-Zrpc-Servers", strings.Join(servers, ","))
+		w.Header().Set("X-Zrpc-Weights", strings.Join(weights, ","))
 	case "POST":
 		addr := req.Header.Get("X-Zrpc-Server")
 		log.Println("receive heartbeat:", addr)
@@ -71,7 +82,8 @@ func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		r.putServer(addr)
+		weight, _ := strconv.Atoi(req.Header.Get("X-Zrpc-Weight"))
+		r.putServer(addr, weight)
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
@@ -89,6 +101,12 @@ func HandleHTTP() *http.ServeMux {
 }
 
 func Heartbeat(registry string, addr string, duration time.Duration) {
+	HeartbeatWithWeight(registry, addr, 0, duration)
+}
+
+// HeartbeatWithWeight also reports the weight of the server, used by
+// the weighted select modes of xclient
+func HeartbeatWithWeight(registry string, addr string, weight int, duration time.Duration) {
 	if duration == 0 {
 		duration = defaultTimeout - time.Minute
 	}
@@ -96,17 +114,20 @@ func Heartbeat(registry string, addr string, duration time.Duration) {
 	go func() {
 		t := time.NewTicker(duration)
 		for err == nil {
-			err = sendHeartbeat(registry, addr)
+			err = sendHeartbeat(registry, addr, weight)
 			<-t.C
 		}
 	}()
 }
 
-func sendHeartbeat(registry string, addr string) error {
+func sendHeartbeat(registry string, addr string, weight int) error {
 	log.Println(addr, "send heartbeat to registry", registry)
 	client := &http.Client{}
 	req, _ := http.NewRequest("POST", registry, nil)
 	req.Header.Set("X-Zrpc-Server", addr)
+	if weight > 0 {
+		req.Header.Set("X-Zrpc-Weight", strconv.Itoa(weight))
+	}
 	_, err := client.Do(req)
 	if err != nil {
 		log.Println("rpc server: heartbeat error:", err)
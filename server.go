@@ -1,6 +1,7 @@
 package zrpc
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -9,7 +10,9 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/rpc"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -29,30 +32,129 @@ var DefaultOption = &Option{
 	ConnectTimeout: time.Second * 10,
 }
 
+type ServerOption struct {
+	Strict            bool                         // Register fails with a *RegistrationError instead of skipping methods that can not be served
+	Concurrency       *ConcurrencyLimit            // of the whole server, nil for no limit
+	MethodConcurrency map[string]*ConcurrencyLimit // by "Service.Method" or "Service", see SetConcurrencyLimit
+	RateLimits        map[string]*RateLimit        // by "Service.Method", "Service" or "" for the server, see SetRateLimit
+	Admission         *AdmissionOption             // adaptive load shedding, nil to disable, see SetAdmission
+}
+
+var DefaultServerOption = &ServerOption{}
+
 type Server struct {
-	serviceMap sync.Map
+	opt           *ServerOption
+	serviceMap    sync.Map
+	health        *Health
+	mux           sync.Mutex // protect following
+	debugSections []debugSection
+	listeners     map[net.Listener]struct{}
+	shutdown      bool
+	limiters      map[string]*limiter
+	rateLimiters  map[string]*rateLimiter
+	admission     *admission
 }
 
+// Register publishes the methods of receiver as the service named after its type
 func (server *Server) Register(receiver interface{}) error {
-	s := newService(receiver)
+	return server.RegisterName("", receiver)
+}
+
+func Register(receiver interface{}) error {
+	return DefaultServer.Register(receiver)
+}
+
+// RegisterName publishes the methods of receiver as the service name, e.g.
+// "Foo/v2" to serve "Foo/v2.Sum" next to "Foo.Sum". Names must not contain dots.
+func (server *Server) RegisterName(name string, receiver interface{}) error {
+	s, err := server.newService(name, receiver)
+	if err != nil {
+		return err
+	}
 	_, dup := server.serviceMap.LoadOrStore(s.name, s)
 	if dup {
 		return errors.New("rpc server: service already exists: " + s.name)
 	}
+	s.logMethods("register")
 	return nil
 }
 
-func Register(receiver interface{}) error {
-	return DefaultServer.Register(receiver)
+func RegisterName(name string, receiver interface{}) error {
+	return DefaultServer.RegisterName(name, receiver)
+}
+
+// Replace is RegisterName, but takes the place of the service already
+// registered under the name, if any. Calls already running complete on the
+// old receiver, later calls go to the new one, none fails in between.
+func (server *Server) Replace(name string, receiver interface{}) error {
+	s, err := server.newService(name, receiver)
+	if err != nil {
+		return err
+	}
+	server.serviceMap.Store(s.name, s)
+	s.logMethods("replace")
+	return nil
+}
+
+func Replace(name string, receiver interface{}) error {
+	return DefaultServer.Replace(name, receiver)
+}
+
+func (server *Server) newService(name string, receiver interface{}) (*service, error) {
+	s, err := newService(name, receiver)
+	if err != nil {
+		return nil, err
+	}
+	if server.opt.Strict && (len(s.rejected) > 0 || len(s.method) == 0) {
+		return nil, &RegistrationError{Service: s.name, Rejected: s.rejected}
+	}
+	return s, nil
+}
+
+func (s *service) logMethods(action string) {
+	names := make([]string, 0, len(s.method))
+	for name := range s.method {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		log.Printf("rpc server: %s %s.%s\n", action, s.name, name)
+	}
+}
+
+// builtinPrefix names the built-in services, e.g. "zrpc.Health". Names passed
+// to RegisterName can not contain dots, so they never collide with them.
+const builtinPrefix = "zrpc."
+
+func isBuiltin(name string) bool {
+	return strings.HasPrefix(name, builtinPrefix)
+}
+
+// Unregister removes the service name, calls already running complete.
+// Use Replace to swap a service without a gap.
+func (server *Server) Unregister(name string) error {
+	if isBuiltin(name) {
+		return errors.New("rpc server: can not unregister built-in service " + name)
+	}
+	if _, ok := server.serviceMap.LoadAndDelete(name); !ok {
+		return errors.New("rpc server: can not find service " + name)
+	}
+	server.health.remove(name)
+	log.Printf("rpc server: unregister %s\n", name)
+	return nil
+}
+
+func Unregister(name string) error {
+	return DefaultServer.Unregister(name)
 }
 
 func (server *Server) findService(serviceMethod string) (svc *service, mType *methodType, err error) {
-	tokens := strings.Split(serviceMethod, ".")
-	if len(tokens) != 2 {
+	dot := strings.LastIndex(serviceMethod, ".")
+	if dot <= 0 || dot == len(serviceMethod)-1 {
 		err = errors.New("rpc server: invalid name, should be service.method")
 		return
 	}
-	serviceName, methodName := tokens[0], tokens[1]
+	serviceName, methodName := serviceMethod[:dot], serviceMethod[dot+1:]
 	s, ok := server.serviceMap.Load(serviceName)
 	if !ok {
 		err = errors.New("rpc server: can not find service " + serviceName)
@@ -66,23 +168,98 @@ func (server *Server) findService(serviceMethod string) (svc *service, mType *me
 	return
 }
 
-func NewServer() *Server {
-	return &Server{}
+// NewServer creates a Server, DefaultServerOption is used if opts are not given
+func NewServer(opts ...*ServerOption) *Server {
+	opt := DefaultServerOption
+	if len(opts) > 0 && opts[0] != nil {
+		opt = opts[0]
+	}
+	server := &Server{
+		opt:          opt,
+		listeners:    make(map[net.Listener]struct{}),
+		limiters:     make(map[string]*limiter),
+		rateLimiters: make(map[string]*rateLimiter),
+	}
+	server.SetConcurrencyLimit("", opt.Concurrency)
+	for name, limit := range opt.MethodConcurrency {
+		server.SetConcurrencyLimit(name, limit)
+	}
+	for name, limit := range opt.RateLimits {
+		server.SetRateLimit(name, limit)
+	}
+	server.SetAdmission(opt.Admission)
+	server.AddDebugSection("Concurrency Limits", server.concurrencyStatus)
+	server.AddDebugSection("Rate Limits", server.rateLimitStatus)
+	server.AddDebugSection("Admission", server.admissionStatus)
+	server.health = newHealth(server)
+	// built-in services are registered quietly, every program importing zrpc creates DefaultServer
+	for _, receiver := range []interface{}{server.health, &Reflection{server: server}} {
+		s, _ := newService("", receiver)
+		s.name = builtinPrefix + s.name
+		server.serviceMap.Store(s.name, s)
+	}
+	return server
 }
 
 var DefaultServer = NewServer()
 
-func (server *Server) Accept(listener net.Listener) {
+// trackListener returns false if the server is already shut down
+func (server *Server) trackListener(listener net.Listener, add bool) bool {
+	server.mux.Lock()
+	defer server.mux.Unlock()
+	if add {
+		if server.shutdown {
+			return false
+		}
+		server.listeners[listener] = struct{}{}
+	} else {
+		delete(server.listeners, listener)
+	}
+	return true
+}
+
+// acceptLoop serves every connection of listener until it is closed
+func (server *Server) acceptLoop(listener net.Listener, serve func(conn io.ReadWriteCloser)) {
+	if !server.trackListener(listener, true) {
+		_ = listener.Close()
+		return
+	}
+	defer server.trackListener(listener, false)
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			log.Println("rpc server: accept error:", err)
+			if !server.isShutdown() {
+				log.Println("rpc server: accept error:", err)
+			}
 			return
 		}
-		go server.ServeConn(conn)
+		go serve(conn)
+	}
+}
+
+func (server *Server) isShutdown() bool {
+	server.mux.Lock()
+	defer server.mux.Unlock()
+	return server.shutdown
+}
+
+// Shutdown reports NOT_SERVING for the server and every service, then closes
+// the listeners of Accept, AcceptJSONRPC, AcceptNetRPC and ServeHTTP2. Connections already
+// established are served until their clients close them.
+func (server *Server) Shutdown() {
+	server.health.shutdown()
+	server.mux.Lock()
+	defer server.mux.Unlock()
+	server.shutdown = true
+	for listener := range server.listeners {
+		_ = listener.Close()
 	}
 }
 
+func (server *Server) Accept(listener net.Listener) {
+	server.acceptLoop(listener, server.ServeConn)
+}
+
 func Accept(listener net.Listener) {
 	DefaultServer.Accept(listener)
 }
@@ -97,7 +274,9 @@ func (server *Server) ServeConn(conn io.ReadWriteCloser) {
 	protocol := make([]uint64, 3)
 	err := binary.Read(conn, binary.BigEndian, protocol)
 	if err != nil {
-		log.Println("rpc server: option error:", err)
+		if err != io.EOF { // e.g. a TCP health probe
+			log.Println("rpc server: option error:", err)
+		}
 		return
 	}
 	opt.MagicNumber = protocol[0]
@@ -112,21 +291,27 @@ func (server *Server) ServeConn(conn io.ReadWriteCloser) {
 		log.Printf("zrpc server: invalid codec type %d", opt.CodecType)
 		return
 	}
-	server.ServeCodec(f(conn))
+	server.serveCodec(f(conn), newCaller(conn))
 }
 
 var invalidRequest = struct{}{}
 
 func (server *Server) ServeCodec(c codec.Codec) {
+	server.serveCodec(c, nil)
+}
+
+// serveCodec serves requests from caller, nil if unknown
+func (server *Server) serveCodec(c codec.Codec, caller *caller) {
 	sending := new(sync.Mutex)
 	wg := new(sync.WaitGroup)
 	for {
-		req, err := server.readRequest(c)
+		req, err := server.readRequest(c, caller)
 		if err != nil {
 			if req == nil {
 				break
 			}
 			req.header.Error = err.Error()
+			req.header.Metadata = nil
 			server.sendResponse(c, req.header, invalidRequest, sending)
 			continue
 		}
@@ -138,11 +323,12 @@ func (server *Server) ServeCodec(c codec.Codec) {
 }
 
 type request struct {
-	header *codec.Header
-	argv   reflect.Value
-	replyv reflect.Value
-	mType  *methodType
-	svc    *service
+	header   *codec.Header
+	argv     reflect.Value
+	replyv   reflect.Value
+	mType    *methodType
+	svc      *service
+	received time.Time // the queueing delay is measured from here
 }
 
 func (server *Server) readRequestHeader(c codec.Codec) (*codec.Header, error) {
@@ -156,14 +342,29 @@ func (server *Server) readRequestHeader(c codec.Codec) (*codec.Header, error) {
 	return &header, nil
 }
 
-func (server *Server) readRequest(c codec.Codec) (*request, error) {
+func (server *Server) readRequest(c codec.Codec, caller *caller) (*request, error) {
 	header, err := server.readRequestHeader(c)
 	if err != nil {
 		return nil, err
 	}
-	req := &request{header: header}
+	req := &request{header: header, received: time.Now()}
 	req.svc, req.mType, err = server.findService(header.ServiceMethod)
 	if err != nil {
+		// discard the body to keep the stream in sync for the next request
+		_ = c.ReadBody(nil)
+		return req, err
+	}
+	// rejected calls cost no decoding
+	if err = server.allowRate(caller, req.svc.name, header.ServiceMethod, header.Metadata); err != nil {
+		_ = c.ReadBody(nil)
+		return req, err
+	}
+	if err = server.rejectFull(req.svc.name, header.ServiceMethod); err != nil {
+		_ = c.ReadBody(nil)
+		return req, err
+	}
+	if err = server.shedEarly(header.Priority); err != nil {
+		_ = c.ReadBody(nil)
 		return req, err
 	}
 	req.argv = req.mType.newArgv()
@@ -197,16 +398,49 @@ func (server *Server) handleRequest(c codec.Codec, req *request, sending *sync.M
 	called := make(chan struct{}, 1)
 	sent := make(chan struct{}, 1)
 	timeout := req.header.Timeout
+	timeoutError := fmt.Sprintf("rpc server: handle request timeout: expect within %s", timeout)
+	ctx := context.Background()
+	if req.header.Metadata != nil {
+		ctx = context.WithValue(ctx, metadataKey{}, req.header.Metadata)
+	}
+	if req.header.Priority != PriorityNormal {
+		ctx = WithPriority(ctx, req.header.Priority)
+	}
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	// only the first of the reply and the timeout error is sent
+	var once sync.Once
+	respond := func(errMsg string, body interface{}) {
+		once.Do(func() {
+			header := *req.header
+			header.Error = errMsg
+			header.Metadata = nil // not echoed back
+			server.sendResponse(c, &header, body, sending)
+		})
+	}
 	go func() {
-		err := req.svc.call(req.mType, req.argv, req.replyv)
+		defer cancel()
+		release, err := server.admit(ctx, req.svc.name, req.header.ServiceMethod)
+		if err == nil {
+			// the wait for a concurrency slot counts as queueing delay
+			if err = server.shed(req.received, req.header.Priority); err == nil {
+				err = req.svc.callContext(ctx, req.mType, req.argv, req.replyv)
+			}
+			release()
+		}
 		called <- struct{}{}
-		if err != nil {
-			req.header.Error = err.Error()
-			server.sendResponse(c, req.header, invalidRequest, sending)
-			sent <- struct{}{}
-			return
+		switch {
+		case err != nil && ctx.Err() == context.DeadlineExceeded:
+			respond(timeoutError, invalidRequest)
+		case err != nil:
+			respond(err.Error(), invalidRequest)
+		default:
+			respond("", req.replyv.Interface())
 		}
-		server.sendResponse(c, req.header, req.replyv.Interface(), sending)
 		sent <- struct{}{}
 	}()
 	if timeout == 0 {
@@ -216,8 +450,7 @@ func (server *Server) handleRequest(c codec.Codec, req *request, sending *sync.M
 	}
 	select {
 	case <-time.After(timeout):
-		req.header.Error = fmt.Sprintf("rpc server: handle request timeout: expect within %s", timeout)
-		server.sendResponse(c, req.header, invalidRequest, sending)
+		respond(timeoutError, invalidRequest)
 	case <-called:
 		<-sent
 	}
@@ -249,6 +482,10 @@ func (server *Server) HandleHTTP() *http.ServeMux {
 	handler := http.NewServeMux()
 	handler.Handle(defaultRPCPath, server)
 	handler.Handle(defaultDebugPath, debugHTTP{server})
+	handler.Handle(defaultJSONRPCPath, jsonRPCHTTP{server})
+	handler.Handle(rpc.DefaultRPCPath, netRPCHTTP{server})
+	handler.Handle(defaultH2Path, h2Handler{server})
+	handler.Handle(defaultHealthPath, healthHTTP{server})
 	log.Println("rpc server debug path:", defaultDebugPath)
 	return handler
 }
@@ -1,17 +1,22 @@
 package zrpc
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"go/ast"
-	"log"
 	"reflect"
+	"strings"
 	"sync/atomic"
 )
 
 type methodType struct {
-	method    reflect.Method
-	ArgType   reflect.Type
-	ReplyType reflect.Type
-	numCalls  uint64
+	method      reflect.Method
+	fn          reflect.Value // set instead of method by HandleFunc
+	withContext bool          // fn takes a context.Context first
+	ArgType     reflect.Type
+	ReplyType   reflect.Type
+	numCalls    uint64
 }
 
 func (m *methodType) NumCalls() uint64 {
@@ -44,52 +49,118 @@ type service struct {
 	receiverType reflect.Type
 	receiver     reflect.Value
 	method       map[string]*methodType
+	rejected     []RejectedMethod
 }
 
-func newService(receiver interface{}) *service {
+// newService names the service after the type of receiver if name is "",
+// the type has to be exported then
+func newService(name string, receiver interface{}) (*service, error) {
+	if receiver == nil {
+		return nil, errors.New("rpc server: receiver is nil")
+	}
 	s := new(service)
 	s.receiver = reflect.ValueOf(receiver)
 	s.receiverType = reflect.TypeOf(receiver)
-	s.name = reflect.Indirect(s.receiver).Type().Name()
-	if !ast.IsExported(s.name) {
-		log.Fatalf("rpc server: %s is not exported", s.name)
+	s.name = name
+	if name == "" {
+		s.name = reflect.Indirect(s.receiver).Type().Name()
+		if !ast.IsExported(s.name) {
+			return nil, fmt.Errorf("rpc server: %s is not exported", s.name)
+		}
+	}
+	if s.name == "" || strings.Contains(s.name, ".") {
+		return nil, fmt.Errorf("rpc server: invalid service name '%s'", s.name)
 	}
 	s.registerMethods()
-	return s
+	return s, nil
 }
 
+// registerMethods keeps the reason for every exported method it skips in s.rejected
 func (s *service) registerMethods() {
 	s.method = make(map[string]*methodType)
+	s.rejected = nil
 	for i := 0; i < s.receiverType.NumMethod(); i++ {
 		method := s.receiverType.Method(i)
-		mType := method.Type
-		if mType.NumIn() != 3 || mType.NumOut() != 1 {
-			continue
-		}
-		if mType.Out(0) != reflect.TypeOf((*error)(nil)).Elem() {
-			continue
-		}
-		argType, replyType := mType.In(1), mType.In(2)
-		if !isExportedOrBuiltinType(argType) || !isExportedOrBuiltinType(replyType) {
+		if reason := checkMethod(method.Type); reason != "" {
+			s.rejected = append(s.rejected, RejectedMethod{Name: method.Name, Reason: reason})
 			continue
 		}
 		s.method[method.Name] = &methodType{
 			method:    method,
-			ArgType:   argType,
-			ReplyType: replyType,
+			ArgType:   method.Type.In(1),
+			ReplyType: method.Type.In(2),
 		}
-		log.Printf("rpc server: register %s.%s\n", s.name, method.Name)
 	}
 }
 
+// checkMethod returns why a method of type mType, including the receiver, can not be served
+func checkMethod(mType reflect.Type) string {
+	if mType.NumIn() != 3 {
+		return fmt.Sprintf("has %d arguments, expect 2 (args, reply)", mType.NumIn()-1)
+	}
+	if mType.NumOut() != 1 {
+		return fmt.Sprintf("returns %d values, expect 1 (error)", mType.NumOut())
+	}
+	if mType.Out(0) != typeOfError {
+		return fmt.Sprintf("returns %s, expect error", mType.Out(0))
+	}
+	argType, replyType := mType.In(1), mType.In(2)
+	if !isExportedOrBuiltinType(argType) {
+		return fmt.Sprintf("argument type %s is not exported", argType)
+	}
+	if !isExportedOrBuiltinType(replyType) {
+		return fmt.Sprintf("reply type %s is not exported", replyType)
+	}
+	if replyType.Kind() != reflect.Ptr {
+		return fmt.Sprintf("reply type %s is not a pointer", replyType)
+	}
+	return ""
+}
+
+// RejectedMethod is an exported method registration skipped
+type RejectedMethod struct {
+	Name   string
+	Reason string
+}
+
+// RegistrationError is returned by Register in strict mode, see ServerOption
+type RegistrationError struct {
+	Service  string
+	Rejected []RejectedMethod
+}
+
+func (e *RegistrationError) Error() string {
+	if len(e.Rejected) == 0 {
+		return "rpc server: service " + e.Service + " has no methods"
+	}
+	var sb strings.Builder
+	sb.WriteString("rpc server: service " + e.Service + " has methods that can not be served:")
+	for _, m := range e.Rejected {
+		sb.WriteString("\n\t" + m.Name + ": " + m.Reason)
+	}
+	return sb.String()
+}
+
 func isExportedOrBuiltinType(t reflect.Type) bool {
 	return ast.IsExported(t.Name()) || t.PkgPath() == ""
 }
 
 func (s *service) call(m *methodType, argv reflect.Value, replyv reflect.Value) error {
+	return s.callContext(context.Background(), m, argv, replyv)
+}
+
+// callContext passes ctx to handlers of HandleFunc that take one
+func (s *service) callContext(ctx context.Context, m *methodType, argv reflect.Value, replyv reflect.Value) error {
 	atomic.AddUint64(&m.numCalls, 1)
-	f := m.method.Func
-	retVal := f.Call([]reflect.Value{s.receiver, argv, replyv})
+	var retVal []reflect.Value
+	switch {
+	case !m.fn.IsValid():
+		retVal = m.method.Func.Call([]reflect.Value{s.receiver, argv, replyv})
+	case m.withContext:
+		retVal = m.fn.Call([]reflect.Value{reflect.ValueOf(ctx), argv, replyv})
+	default:
+		retVal = m.fn.Call([]reflect.Value{argv, replyv})
+	}
 	err := retVal[0].Interface()
 	if err != nil {
 		return err.(error)
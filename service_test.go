@@ -1,8 +1,10 @@
 package zrpc
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -36,7 +38,7 @@ func _assert(condition bool, msg string, v ...interface{}) {
 
 func TestNewService(t *testing.T) {
 	var foo Foo
-	s := newService(&foo)
+	s, _ := newService("", &foo)
 	_assert(len(s.method) == 2, "invalid service Method, except 2, but got %d", len(s.method))
 	mType := s.method["Sum"]
 	_assert(mType != nil, "invalid Method, `Sum` is nil")
@@ -44,10 +46,123 @@ func TestNewService(t *testing.T) {
 
 func TestMethodType_Call(t *testing.T) {
 	var foo Foo
-	s := newService(&foo)
+	s, _ := newService("", &foo)
 	mType := s.method["Sum"]
 	argv, replyv := mType.newArgv(), mType.newReplyv()
 	argv.Set(reflect.ValueOf(Args{Num1: 2, Num2: 3}))
 	err := s.call(mType, argv, replyv)
 	_assert(err == nil && *replyv.Interface().(*int) == 5 && mType.numCalls == 1, "failed to call `Foo.Sum`")
 }
+
+type fooV2 struct{}
+
+func (f fooV2) Sum(args Args, reply *int) error {
+	*reply = args.Num1 + args.Num2 + 100
+	return nil
+}
+
+func TestServer_RegisterName(t *testing.T) {
+	server := NewServer()
+	_assert(server.Register(&Foo{}) == nil, "expect Foo to register")
+	_assert(server.RegisterName("Foo/v2", &fooV2{}) == nil, "expect an unexported type to register by name")
+	_assert(server.Register(&fooV2{}) != nil, "expect an error for an unexported type without a name")
+	_assert(server.RegisterName("Foo", &fooV2{}) != nil, "expect an error for a duplicate name")
+	_assert(server.RegisterName("Foo.v3", &fooV2{}) != nil, "expect an error for a name with a dot")
+	_assert(server.Register(nil) != nil, "expect an error for a nil receiver")
+
+	svc, mType, err := server.findService("Foo/v2.Sum")
+	_assert(err == nil && svc.name == "Foo/v2" && mType != nil, "expect to find Foo/v2.Sum, got %v", err)
+	argv, replyv := mType.newArgv(), mType.newReplyv()
+	argv.Set(reflect.ValueOf(Args{Num1: 2, Num2: 3}))
+	_ = svc.call(mType, argv, replyv)
+	_assert(*replyv.Interface().(*int) == 105, "expect Foo/v2 to serve the call")
+	for _, name := range []string{"Foo", ".Sum", "Foo.", "Foo/v3.Sum"} {
+		_, _, err = server.findService(name)
+		_assert(err != nil, "expect an error for %s", name)
+	}
+
+	_assert(server.Unregister("Foo/v2") == nil, "expect Foo/v2 to unregister")
+	_assert(server.Unregister("Foo/v2") != nil, "expect an error for an unknown service")
+	_, _, err = server.findService("Foo/v2.Sum")
+	_assert(err != nil, "expect Foo/v2 to be gone")
+	_assert(server.RegisterName("Foo/v2", &fooV2{}) == nil, "expect Foo/v2 to register again")
+
+	// calls racing with Replace always find a service
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			_assert(server.Replace("Foo/v2", &Foo{}) == nil, "expect Foo/v2 to be replaced")
+		}
+	}()
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		_, _, err = server.findService("Foo/v2.Sum")
+		_assert(err == nil, "expect no gap while replacing, got %v", err)
+	}
+	svc, mType, _ = server.findService("Foo/v2.Sum")
+	argv, replyv = mType.newArgv(), mType.newReplyv()
+	argv.Set(reflect.ValueOf(Args{Num1: 2, Num2: 3}))
+	_ = svc.call(mType, argv, replyv)
+	_assert(*replyv.Interface().(*int) == 5, "expect Foo to serve the call after Replace")
+	_assert(server.Replace("Foo.v3", &Foo{}) != nil, "expect Replace to check the name")
+}
+
+type Lenient struct{}
+
+type hidden struct{}
+
+func (l Lenient) Sum(args Args, reply *int) error       { return nil }
+func (l Lenient) NoReply(args Args) error               { return nil }
+func (l Lenient) NoError(args Args, reply *int)         {}
+func (l Lenient) WrongResult(args Args, reply *int) int { return 0 }
+func (l Lenient) ValueReply(args Args, reply int) error { return nil }
+func (l Lenient) Hidden(args hidden, reply *int) error  { return nil }
+
+type Empty struct{}
+
+func TestServer_Strict(t *testing.T) {
+	_assert(NewServer().Register(&Lenient{}) == nil, "expect methods to be skipped by default")
+	svc, _ := newService("", &Lenient{})
+	_assert(len(svc.method) == 1 && len(svc.rejected) == 5, "expect 1 method and 5 rejected, got %d and %d", len(svc.method), len(svc.rejected))
+
+	server := NewServer(&ServerOption{Strict: true})
+	err := server.Register(&Lenient{})
+	var regErr *RegistrationError
+	_assert(errors.As(err, &regErr) && regErr.Service == "Lenient" && len(regErr.Rejected) == 5, "expect a registration error, got %v", err)
+	reasons := make(map[string]string)
+	for _, m := range regErr.Rejected {
+		reasons[m.Name] = m.Reason
+	}
+	_assert(strings.Contains(reasons["NoReply"], "1 arguments"), "unexpected reason %q", reasons["NoReply"])
+	_assert(strings.Contains(reasons["NoError"], "0 values"), "unexpected reason %q", reasons["NoError"])
+	_assert(strings.Contains(reasons["WrongResult"], "returns int"), "unexpected reason %q", reasons["WrongResult"])
+	_assert(strings.Contains(reasons["ValueReply"], "not a pointer"), "unexpected reason %q", reasons["ValueReply"])
+	_assert(strings.Contains(reasons["Hidden"], "not exported"), "unexpected reason %q", reasons["Hidden"])
+	_, _, err = server.findService("Lenient.Sum")
+	_assert(err != nil, "expect nothing to be registered")
+
+	err = server.Register(&Empty{})
+	_assert(errors.As(err, &regErr) && strings.Contains(err.Error(), "no methods"), "expect an error for no methods, got %v", err)
+	_assert(server.Register(&Foo{}) == nil, "expect Foo to register in strict mode")
+}
+
+func TestServer_Builtin(t *testing.T) {
+	server := NewServer()
+	for _, name := range []string{"Health", "Reflection"} {
+		_assert(server.RegisterName(name, &fooV2{}) == nil, "expect a user service named %s to register", name)
+		svc, _, err := server.findService(name + ".Sum")
+		_assert(err == nil && svc.receiverType == reflect.TypeOf(&fooV2{}), "expect the user service, got %v", err)
+		_assert(server.Unregister(name) == nil, "expect the user service to unregister")
+	}
+	_assert(server.Unregister("zrpc.Health") != nil && server.Unregister("zrpc.Reflection") != nil, "expect built-in services to be protected")
+	_assert(server.HandleFunc("zrpc.Health.Extra", func(args int, reply *int) error { return nil }) != nil, "expect no methods added to a built-in service")
+	for _, name := range []string{"zrpc.Health.Check", "zrpc.Reflection.Describe"} {
+		_, _, err := server.findService(name)
+		_assert(err == nil, "expect %s to stay, got %v", name, err)
+	}
+}
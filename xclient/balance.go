@@ -0,0 +1,125 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// Observer is implemented by discoveries that want feedback about every call
+// XClient sends, e.g. to take the load of each server into account
+type Observer interface {
+	Start(rpcAddr string)
+	Done(rpcAddr string, latency time.Duration, err error)
+}
+
+var _ Observer = (*MultiServerDiscovery)(nil)
+
+const (
+	ewmaAlpha    = 0.3              // weight of the latest sample in the plain EWMA
+	peakDecay    = time.Second * 10 // time constant peak EWMA decays with
+	errorPenalty = time.Second      // latency a call to an unhealthy server counts as, so broken servers do not look fast
+)
+
+// latencyStats is the latency feedback of a single server
+type latencyStats struct {
+	ewma float64 // nanoseconds
+	peak float64 // nanoseconds, jumps to any higher sample and decays over time
+	last time.Time
+}
+
+func (s *latencyStats) observe(latency time.Duration, now time.Time) {
+	sample := float64(latency)
+	if s.last.IsZero() {
+		s.ewma, s.peak, s.last = sample, sample, now
+		return
+	}
+	s.ewma = ewmaAlpha*sample + (1-ewmaAlpha)*s.ewma
+	if sample > s.peak {
+		s.peak = sample
+	} else {
+		w := math.Exp(-float64(now.Sub(s.last)) / float64(peakDecay))
+		s.peak = s.peak*w + sample*(1-w)
+	}
+	s.last = now
+}
+
+func (msd *MultiServerDiscovery) Start(rpcAddr string) {
+	msd.mux.Lock()
+	defer msd.mux.Unlock()
+	msd.inflight[rpcAddr]++
+}
+
+func (msd *MultiServerDiscovery) Done(rpcAddr string, latency time.Duration, err error) {
+	msd.mux.Lock()
+	defer msd.mux.Unlock()
+	if msd.inflight[rpcAddr]--; msd.inflight[rpcAddr] <= 0 {
+		delete(msd.inflight, rpcAddr)
+	}
+	msd.observe(rpcAddr, latency, err)
+}
+
+// observe is called by Done with msd.mux held
+func (msd *MultiServerDiscovery) observe(rpcAddr string, latency time.Duration, err error) {
+	if errors.Is(err, context.Canceled) {
+		return // e.g. the loser of a hedged call, its latency is unknown
+	}
+	if unhealthy(err) && latency < errorPenalty {
+		latency = errorPenalty
+	}
+	s, ok := msd.stats[rpcAddr]
+	if !ok {
+		s = new(latencyStats)
+		msd.stats[rpcAddr] = s
+	}
+	s.observe(latency, time.Now())
+}
+
+func (msd *MultiServerDiscovery) leastPending(servers []string) string {
+	n := len(servers)
+	offset := msd.r.Intn(n) // break ties randomly
+	best := servers[offset]
+	for i := 1; i < n; i++ {
+		server := servers[(offset+i)%n]
+		if msd.inflight[server] < msd.inflight[best] {
+			best = server
+		}
+	}
+	return best
+}
+
+// powerOfTwoChoices samples two servers at random and takes the cheaper one
+func (msd *MultiServerDiscovery) powerOfTwoChoices(servers []string, cost func(server string) float64) string {
+	n := len(servers)
+	if n == 1 {
+		return servers[0]
+	}
+	i := msd.r.Intn(n)
+	j := msd.r.Intn(n - 1)
+	if j >= i {
+		j++
+	}
+	a, b := servers[i], servers[j]
+	if cost(b) < cost(a) {
+		return b
+	}
+	return a
+}
+
+// ewmaCost is the expected latency of a call, weighted by calls already queued
+func (msd *MultiServerDiscovery) ewmaCost(server string) float64 {
+	s := msd.stats[server]
+	if s == nil {
+		return 0 // no feedback yet, try it
+	}
+	return s.ewma * float64(msd.inflight[server]+1)
+}
+
+func (msd *MultiServerDiscovery) peakEWMACost(server string) float64 {
+	s := msd.stats[server]
+	if s == nil {
+		return 0
+	}
+	return s.peak * float64(msd.inflight[server]+1)
+}
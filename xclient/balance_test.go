@@ -0,0 +1,73 @@
+package xclient
+
+import (
+	. "github.com/vlzx/zrpc"
+	"testing"
+	"time"
+)
+
+func TestMultiServerDiscovery_Observe(t *testing.T) {
+	msd := NewMultiServerDiscovery([]string{"a", "b", "c"})
+	cases := []struct {
+		server string
+		err    error
+		want   time.Duration
+	}{
+		{"a", nil, time.Millisecond},
+		{"b", ServerError("business error"), time.Millisecond},
+		{"c", ErrShutdown, errorPenalty},
+	}
+	for _, c := range cases {
+		msd.Start(c.server)
+		_assert(msd.inflight[c.server] == 1, "expect a call in flight")
+		msd.Done(c.server, time.Millisecond, c.err)
+		_assert(msd.inflight[c.server] == 0, "expect no call in flight")
+		got := time.Duration(msd.stats[c.server].ewma)
+		_assert(got == c.want, "%s with error %v: expect latency %s, got %s", c.server, c.err, c.want, got)
+	}
+}
+
+func TestMultiServerDiscovery_LoadAware(t *testing.T) {
+	observe := func(msd *MultiServerDiscovery, server string, latencies ...time.Duration) {
+		for _, latency := range latencies {
+			msd.observe(server, latency, nil)
+		}
+	}
+	cases := []struct {
+		name    string
+		mode    SelectMode
+		servers []string
+		setup   func(msd *MultiServerDiscovery)
+		want    string // the only server picked
+	}{
+		{"least pending", LeastPendingSelect, []string{"a", "b", "c"}, func(msd *MultiServerDiscovery) {
+			msd.inflight["a"], msd.inflight["c"] = 2, 1
+		}, "b"},
+		{"p2c", P2CSelect, []string{"a", "b"}, func(msd *MultiServerDiscovery) {
+			observe(msd, "a", time.Millisecond*10)
+			observe(msd, "b", time.Millisecond)
+		}, "b"},
+		{"p2c by pending", P2CSelect, []string{"a", "b"}, func(msd *MultiServerDiscovery) {
+			observe(msd, "a", time.Millisecond*2)
+			observe(msd, "b", time.Millisecond)
+			msd.inflight["b"] = 3
+		}, "a"},
+		// a slowed down a moment ago, its EWMA is still below b, its peak is not
+		{"p2c after a spike", P2CSelect, []string{"a", "b"}, func(msd *MultiServerDiscovery) {
+			observe(msd, "a", time.Millisecond, time.Millisecond*200)
+			observe(msd, "b", time.Millisecond*80)
+		}, "a"},
+		{"peak ewma after a spike", PeakEWMASelect, []string{"a", "b"}, func(msd *MultiServerDiscovery) {
+			observe(msd, "a", time.Millisecond, time.Millisecond*200)
+			observe(msd, "b", time.Millisecond*80)
+		}, "b"},
+	}
+	for _, c := range cases {
+		msd := NewMultiServerDiscovery(c.servers)
+		c.setup(msd)
+		for i := 0; i < 100; i++ {
+			server, err := msd.get(c.mode, "")
+			_assert(err == nil && server == c.want, "%s: expect %s, got %s", c.name, c.want, server)
+		}
+	}
+}
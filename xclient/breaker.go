@@ -0,0 +1,239 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+var ErrBreakerOpen = errors.New("rpc client: circuit breaker is open")
+
+type BreakerOption struct {
+	Window        time.Duration // failure statistics are reset every window while closed
+	MinRequests   int           // do not trip before this many calls in a window
+	ErrorRate     float64       // trip when failed calls / all calls reaches this
+	SlowCall      time.Duration // calls slower than this count as failed, 0 disables
+	OpenTimeout   time.Duration // stay open this long before letting probe calls through
+	HalfOpenCalls int           // probe calls that have to succeed to close again
+}
+
+var DefaultBreakerOption = &BreakerOption{
+	Window:        time.Second * 10,
+	MinRequests:   10,
+	ErrorRate:     0.5,
+	OpenTimeout:   time.Second * 5,
+	HalfOpenCalls: 1,
+}
+
+// breaker is a circuit breaker of a single server address
+type breaker struct {
+	opt         *BreakerOption
+	mux         sync.Mutex // protect following
+	state       BreakerState
+	windowStart time.Time
+	total       int
+	failures    int
+	openedAt    time.Time
+	probes      int    // probe calls in flight while half-open
+	successes   int    // successful probe calls while half-open
+	period      uint64 // counts half-open periods, tells their probes apart
+}
+
+func newBreaker(opt *BreakerOption) *breaker {
+	return &breaker{opt: opt, windowStart: time.Now()}
+}
+
+// refresh moves an open breaker to half-open once OpenTimeout has passed
+func (b *breaker) refresh(now time.Time) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.opt.OpenTimeout {
+		b.state = StateHalfOpen
+		b.probes, b.successes = 0, 0
+		b.period++
+	}
+}
+
+func (b *breaker) currentState() BreakerState {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.refresh(time.Now())
+	return b.state
+}
+
+// allow reports whether a call may be sent, and counts it as a probe if
+// half-open. The probe, 0 for other calls, is passed on to record.
+func (b *breaker) allow() (probe uint64, ok bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.refresh(time.Now())
+	switch b.state {
+	case StateOpen:
+		return 0, false
+	case StateHalfOpen:
+		if b.probes+b.successes >= b.halfOpenCalls() {
+			return 0, false
+		}
+		b.probes++
+		return b.period, true
+	}
+	return 0, true
+}
+
+func (b *breaker) halfOpenCalls() int {
+	if b.opt.HalfOpenCalls < 1 {
+		return 1
+	}
+	return b.opt.HalfOpenCalls
+}
+
+// ready reports whether allow would let a call through, without counting a probe
+func (b *breaker) ready() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.refresh(time.Now())
+	switch b.state {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		return b.probes+b.successes < b.halfOpenCalls()
+	}
+	return true
+}
+
+// record feeds the outcome of a call allowed before. Application errors
+// returned by the remote method mean the server is healthy, a call cancelled
+// by the caller tells nothing but frees its probe slot. While half-open, only
+// the probes of the current period count.
+func (b *breaker) record(probe uint64, err error, latency time.Duration) {
+	cancelled := errors.Is(err, context.Canceled)
+	failed := unhealthy(err) || (b.opt.SlowCall > 0 && latency > b.opt.SlowCall)
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	now := time.Now()
+	if b.state == StateHalfOpen && (probe != b.period || b.probes == 0) {
+		return
+	}
+	if cancelled {
+		if b.state == StateHalfOpen {
+			b.probes--
+		}
+		return
+	}
+	switch b.state {
+	case StateHalfOpen:
+		b.probes--
+		if failed {
+			b.open(now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.halfOpenCalls() {
+			b.state = StateClosed
+			b.windowStart, b.total, b.failures = now, 0, 0
+		}
+	case StateClosed:
+		if now.Sub(b.windowStart) > b.opt.Window {
+			b.windowStart, b.total, b.failures = now, 0, 0
+		}
+		b.total++
+		if failed {
+			b.failures++
+		}
+		if b.total >= b.opt.MinRequests && float64(b.failures)/float64(b.total) >= b.opt.ErrorRate {
+			b.open(now)
+		}
+	}
+}
+
+func (b *breaker) open(now time.Time) {
+	b.state = StateOpen
+	b.openedAt = now
+	b.probes, b.successes = 0, 0
+}
+
+// SetBreakerOption enables a circuit breaker per server address, nil disables
+// them again. Fields left at zero take the value of DefaultBreakerOption.
+func (xc *XClient) SetBreakerOption(opt *BreakerOption) {
+	if opt != nil {
+		copied := *opt
+		if copied.Window <= 0 {
+			copied.Window = DefaultBreakerOption.Window
+		}
+		if copied.MinRequests <= 0 {
+			copied.MinRequests = DefaultBreakerOption.MinRequests
+		}
+		if copied.ErrorRate <= 0 {
+			copied.ErrorRate = DefaultBreakerOption.ErrorRate
+		}
+		if copied.OpenTimeout <= 0 {
+			copied.OpenTimeout = DefaultBreakerOption.OpenTimeout
+		}
+		if copied.HalfOpenCalls <= 0 {
+			copied.HalfOpenCalls = DefaultBreakerOption.HalfOpenCalls
+		}
+		opt = &copied
+	}
+	xc.mux.Lock()
+	defer xc.mux.Unlock()
+	xc.bopt = opt
+	xc.breakers = make(map[string]*breaker)
+}
+
+// getBreaker returns nil if circuit breakers are disabled
+func (xc *XClient) getBreaker(rpcAddr string) *breaker {
+	xc.mux.Lock()
+	defer xc.mux.Unlock()
+	if xc.bopt == nil {
+		return nil
+	}
+	b, ok := xc.breakers[rpcAddr]
+	if !ok {
+		b = newBreaker(xc.bopt)
+		xc.breakers[rpcAddr] = b
+	}
+	return b
+}
+
+// available tells whether selection should consider rpcAddr, a half-open
+// breaker is only available while it has probe slots left
+func (xc *XClient) available(rpcAddr string) bool {
+	b := xc.getBreaker(rpcAddr)
+	return b == nil || b.ready()
+}
+
+// BreakerStates returns the breaker state of every address called so far,
+// e.g. to be shown on the debug page by zrpc.Server.AddDebugSection
+func (xc *XClient) BreakerStates() map[string]string {
+	xc.mux.Lock()
+	breakers := make(map[string]*breaker, len(xc.breakers))
+	for rpcAddr, b := range xc.breakers {
+		breakers[rpcAddr] = b
+	}
+	xc.mux.Unlock()
+	states := make(map[string]string, len(breakers))
+	for rpcAddr, b := range breakers {
+		states[rpcAddr] = b.currentState().String()
+	}
+	return states
+}
@@ -0,0 +1,66 @@
+package xclient
+
+import (
+	"context"
+	. "github.com/vlzx/zrpc"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	b := newBreaker(&BreakerOption{Window: time.Second, MinRequests: 2, ErrorRate: 0.5, OpenTimeout: time.Millisecond * 20, HalfOpenCalls: 1})
+	b.record(0, nil, time.Millisecond)
+	_assert(b.currentState() == StateClosed, "expect closed below MinRequests")
+	b.record(0, ServerError("business error"), time.Millisecond)
+	_assert(b.currentState() == StateClosed, "expect application errors not to count")
+	b.record(0, ErrShutdown, time.Millisecond)
+	b.record(0, ErrShutdown, time.Millisecond)
+	_, ok := b.allow()
+	_assert(b.currentState() == StateOpen && !ok, "expect open at the error rate")
+
+	time.Sleep(time.Millisecond * 30)
+	_assert(b.currentState() == StateHalfOpen && b.ready(), "expect half-open after OpenTimeout")
+	probe, ok := b.allow()
+	_, again := b.allow()
+	_assert(ok && probe != 0 && !b.ready() && !again, "expect a single probe")
+	// calls admitted while still closed do not count as probes
+	b.record(0, nil, time.Millisecond)
+	b.record(0, nil, time.Millisecond)
+	_assert(b.currentState() == StateHalfOpen && !b.ready(), "expect stale outcomes to be ignored")
+	// e.g. the loser of a hedged call
+	b.record(probe, context.Canceled, time.Millisecond)
+	_assert(b.ready(), "expect a cancelled probe to free its slot")
+	b.record(probe, nil, time.Millisecond)
+	_assert(b.currentState() == StateHalfOpen, "expect a freed probe not to count twice")
+	probe, ok = b.allow()
+	_assert(ok, "expect a probe")
+	b.record(probe, nil, time.Millisecond)
+	_assert(b.currentState() == StateClosed, "expect closed after a successful probe")
+}
+
+func TestXClient_Breaker(t *testing.T) {
+	addrs, echos, stop := startServers(2)
+	defer stop()
+	xc := NewXClient(NewMultiServerDiscovery(addrs), RoundRobinSelect, nil)
+	defer func() { _ = xc.Close() }()
+
+	xc.SetBreakerOption(&BreakerOption{})
+	_assert(*xc.bopt == *DefaultBreakerOption, "expect defaults for fields left at zero")
+	var reply string
+	_ = xc.Call("Echo.Name", 0, &reply, context.Background())
+	_assert(xc.getBreaker(addrs[0]).currentState() == StateClosed, "expect a successful call to keep the breaker closed")
+
+	// a half-open breaker whose probe slot is taken is skipped
+	b := xc.getBreaker(addrs[0])
+	b.mux.Lock()
+	b.open(time.Now().Add(-time.Minute))
+	b.mux.Unlock()
+	_, ok := b.allow()
+	_assert(ok, "expect a probe")
+	calls := echos[0].numCalls()
+	for i := 0; i < 4; i++ {
+		err := xc.Call("Echo.Name", 0, &reply, context.Background())
+		_assert(err == nil && reply == "1", "expect calls to go to the other server, got %v", err)
+	}
+	_assert(echos[0].numCalls() == calls, "expect no call to the half-open server")
+}
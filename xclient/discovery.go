@@ -6,6 +6,8 @@ import (
 	"math"
 	"math/rand"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,8 +18,16 @@ type SelectMode int
 const (
 	RandomSelect = iota
 	RoundRobinSelect
+	WeightedRandomSelect
+	WeightedRoundRobinSelect // smooth weighted round-robin, as in nginx
+	ConsistentHashSelect     // by the routing key of the call, random if there is none
+	LeastPendingSelect       // fewest calls in flight
+	P2CSelect                // power of two choices by EWMA latency
+	PeakEWMASelect           // power of two choices by peak EWMA latency, reacts faster to slowdowns
 )
 
+const defaultWeight = 1
+
 type Discovery interface {
 	Refresh() error
 	Update(servers []string) error
@@ -27,21 +37,92 @@ type Discovery interface {
 
 // MultiServerDiscovery manual discovery without a registry center
 type MultiServerDiscovery struct {
-	r       *rand.Rand
-	mux     sync.RWMutex
-	servers []string
-	index   int
+	r        *rand.Rand
+	mux      sync.RWMutex
+	servers  []string
+	index    int
+	weights  map[string]int // servers without a weight have defaultWeight
+	current  map[string]int // current weights of smooth weighted round-robin
+	hopt     *HashOption
+	ring     *hashRing      // built on demand, reset whenever servers change
+	inflight map[string]int // calls in flight per server, fed back by XClient
+	stats    map[string]*latencyStats
+	ejected  map[string]time.Time // left out of selection until then
 }
 
 func NewMultiServerDiscovery(servers []string) *MultiServerDiscovery {
 	msd := &MultiServerDiscovery{
-		servers: servers,
-		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		servers:  servers,
+		r:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		weights:  make(map[string]int),
+		current:  make(map[string]int),
+		hopt:     DefaultHashOption,
+		inflight: make(map[string]int),
+		stats:    make(map[string]*latencyStats),
+		ejected:  make(map[string]time.Time),
 	}
 	msd.index = msd.r.Intn(math.MaxInt32 - 1)
 	return msd
 }
 
+// NewWeightedMultiServerDiscovery takes the weight of each server, e.g. higher
+// for newer hardware generations and lower for canary instances
+func NewWeightedMultiServerDiscovery(servers map[string]int) *MultiServerDiscovery {
+	addrs := make([]string, 0, len(servers))
+	for server := range servers {
+		addrs = append(addrs, server)
+	}
+	sort.Strings(addrs)
+	msd := NewMultiServerDiscovery(addrs)
+	for server, weight := range servers {
+		msd.weights[server] = weight
+	}
+	return msd
+}
+
+func (msd *MultiServerDiscovery) SetWeight(server string, weight int) {
+	msd.mux.Lock()
+	defer msd.mux.Unlock()
+	msd.weights[server] = weight
+}
+
+func (msd *MultiServerDiscovery) weight(server string) int {
+	if w, ok := msd.weights[server]; ok && w > 0 {
+		return w
+	}
+	return defaultWeight
+}
+
+func (msd *MultiServerDiscovery) weightedRandom(servers []string) string {
+	total := 0
+	for _, server := range servers {
+		total += msd.weight(server)
+	}
+	n := msd.r.Intn(total)
+	for _, server := range servers {
+		n -= msd.weight(server)
+		if n < 0 {
+			return server
+		}
+	}
+	return servers[len(servers)-1]
+}
+
+func (msd *MultiServerDiscovery) weightedRoundRobin(servers []string) string {
+	total := 0
+	var best string
+	for _, server := range servers {
+		w := msd.weight(server)
+		msd.current[server] += w
+		total += w
+		if best == "" || msd.current[server] > msd.current[best] {
+			best = server
+		}
+	}
+	msd.current[best] -= total
+	return best
+}
+
 var _ Discovery = (*MultiServerDiscovery)(nil)
 
 func (msd *MultiServerDiscovery) Refresh() error {
@@ -52,23 +133,45 @@ func (msd *MultiServerDiscovery) Update(servers []string) error {
 	msd.mux.Lock()
 	defer msd.mux.Unlock()
 	msd.servers = servers
+	msd.current = make(map[string]int)
+	msd.ring = nil
 	return nil
 }
 
 func (msd *MultiServerDiscovery) Get(mode SelectMode) (string, error) {
 	msd.mux.Lock()
 	defer msd.mux.Unlock()
-	n := len(msd.servers)
+	return msd.get(mode, "")
+}
+
+func (msd *MultiServerDiscovery) get(mode SelectMode, key string) (string, error) {
+	servers := msd.selectable()
+	n := len(servers)
 	if n == 0 {
 		return "", errors.New("rpc discovery: no available servers")
 	}
 	switch mode {
 	case RandomSelect:
-		return msd.servers[msd.r.Intn(n)], nil
+		return servers[msd.r.Intn(n)], nil
 	case RoundRobinSelect:
-		s := msd.servers[msd.index%n] // servers num could change, mod n to avoid out of range error
+		s := servers[msd.index%n] // servers num could change, mod n to avoid out of range error
 		msd.index = (msd.index + 1) % n
 		return s, nil
+	case WeightedRandomSelect:
+		return msd.weightedRandom(servers), nil
+	case WeightedRoundRobinSelect:
+		return msd.weightedRoundRobin(servers), nil
+	case ConsistentHashSelect:
+		if key == "" {
+			return servers[msd.r.Intn(n)], nil
+		}
+		return msd.consistentHash(key, servers), nil
+	case LeastPendingSelect:
+		return msd.leastPending(servers), nil
+	case P2CSelect:
+		return msd.powerOfTwoChoices(servers, msd.ewmaCost), nil
+	case PeakEWMASelect:
+		return msd.powerOfTwoChoices(servers, msd.peakEWMACost), nil
 	default:
 		return "", errors.New("rpc discovery: unsupported select mode")
 	}
@@ -107,6 +210,8 @@ func (d *RegistryDiscovery) Update(servers []string) error {
 	d.mux.Lock()
 	defer d.mux.Unlock()
 	d.servers = servers
+	d.current = make(map[string]int)
+	d.ring = nil
 	d.lastUpdate = time.Now()
 	return nil
 }
@@ -124,11 +229,18 @@ func (d *RegistryDiscovery) Refresh() error {
 		return err
 	}
 	servers := strings.Split(resp.Header.Get("X-Zrpc-Servers"), ",")
+	weights := strings.Split(resp.Header.Get("X-Zrpc-Weights"), ",")
 	d.servers = make([]string, 0, len(servers))
-	for _, server := range servers {
+	d.weights = make(map[string]int, len(servers))
+	d.current = make(map[string]int)
+	d.ring = nil
+	for i, server := range servers {
 		server = strings.TrimSpace(server)
 		if server != "" {
 			d.servers = append(d.servers, server)
+			if i < len(weights) {
+				d.weights[server], _ = strconv.Atoi(strings.TrimSpace(weights[i]))
+			}
 		}
 	}
 	d.lastUpdate = time.Now()
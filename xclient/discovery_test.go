@@ -0,0 +1,54 @@
+package xclient
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestMultiServerDiscovery_WeightedRoundRobin(t *testing.T) {
+	cases := []struct {
+		weights map[string]int
+		want    string // one period of picks
+	}{
+		{map[string]int{"a": 1, "b": 1, "c": 1}, "abc"},
+		{map[string]int{"a": 5, "b": 1, "c": 1}, "aabacaa"},
+		{map[string]int{"a": 3, "b": 2, "c": 0}, "abacba"}, // no weight counts as 1
+	}
+	for _, c := range cases {
+		msd := NewWeightedMultiServerDiscovery(c.weights)
+		var picks strings.Builder
+		for i := 0; i < len(c.want)*2; i++ {
+			server, err := msd.get(WeightedRoundRobinSelect, "")
+			_assert(err == nil, "get error: %v", err)
+			picks.WriteString(server)
+		}
+		_assert(picks.String() == c.want+c.want, "weights %v: expect %s twice, got %s", c.weights, c.want, picks.String())
+	}
+}
+
+func TestMultiServerDiscovery_WeightedRandom(t *testing.T) {
+	cases := []map[string]int{
+		{"a": 1, "b": 1},
+		{"a": 8, "b": 1, "c": 1},
+		{"a": 3, "b": 0},
+	}
+	const n = 20000
+	for _, weights := range cases {
+		msd := NewWeightedMultiServerDiscovery(weights)
+		msd.r = rand.New(rand.NewSource(1))
+		counts := make(map[string]int)
+		for i := 0; i < n; i++ {
+			server, _ := msd.get(WeightedRandomSelect, "")
+			counts[server]++
+		}
+		total := 0
+		for server := range weights {
+			total += msd.weight(server)
+		}
+		for server := range weights {
+			want := n * msd.weight(server) / total
+			_assert(counts[server] > want*9/10 && counts[server] < want*11/10, "weights %v: expect about %d picks of %s, got %d", weights, want, server, counts[server])
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+type GatherResult struct {
+	Addr      string
+	Reply     interface{} // a new value of the reply type passed to Gather, nil if Err is set
+	Err       error
+	Cancelled bool // by Gather once the quorum was reached or could not be reached anymore, Err is context.Canceled then
+}
+
+// Gather calls serviceMethod on every server and returns the result of each
+// one in the order of Discovery.GetAll, including calls that failed or were
+// still running when ctx expired. With quorum > 0 it succeeds as soon as quorum
+// servers replied and cancels the rest, otherwise every server has to succeed.
+func (xc *XClient) Gather(serviceMethod string, args interface{}, reply interface{}, quorum int, ctx ...context.Context) ([]*GatherResult, error) {
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(servers) == 0 {
+		return nil, errors.New("rpc gather: no available servers")
+	}
+	defaultCtx := context.Background()
+	if len(ctx) == 1 && ctx[0] != nil {
+		defaultCtx = ctx[0]
+	}
+	need := quorum
+	if need <= 0 || need > len(servers) {
+		need = len(servers)
+	}
+	gatherCtx, cancel := context.WithCancel(defaultCtx)
+	defer cancel()
+	results := make([]*GatherResult, len(servers))
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+	succeeded, failed := 0, 0
+	for i, rpcAddr := range servers {
+		wg.Add(1)
+		go func(i int, rpcAddr string) {
+			defer wg.Done()
+			var clonedReply interface{}
+			if reply != nil {
+				clonedReply = reflect.New(reflect.ValueOf(reply).Elem().Type()).Interface()
+			}
+			err := xc.call(rpcAddr, serviceMethod, args, clonedReply, gatherCtx)
+			result := &GatherResult{Addr: rpcAddr, Err: err}
+			if err == nil {
+				result.Reply = clonedReply
+			}
+			mux.Lock()
+			defer mux.Unlock()
+			results[i] = result
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, context.Canceled) && gatherCtx.Err() != nil && defaultCtx.Err() == nil:
+				result.Cancelled = true
+			default:
+				failed++
+			}
+			// stop early once the quorum is reached, or can not be reached anymore
+			if quorum > 0 && (succeeded >= need || len(servers)-failed < need) {
+				cancel()
+			}
+		}(i, rpcAddr)
+	}
+	wg.Wait()
+	if succeeded < need {
+		return results, fmt.Errorf("rpc gather: %d of %d servers succeeded, need %d", succeeded, len(servers), need)
+	}
+	return results, nil
+}
@@ -0,0 +1,43 @@
+package xclient
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestXClient_Gather(t *testing.T) {
+	addrs, echos, stop := startServers(3)
+	defer stop()
+	xc := NewXClient(NewMultiServerDiscovery(addrs), RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+	var reply string
+
+	results, err := xc.Gather("Echo.Name", 0, &reply, 0)
+	_assert(err == nil && len(results) == 3, "expect every server to succeed, got %v", err)
+	for i, result := range results {
+		_assert(result.Addr == addrs[i] && *result.Reply.(*string) == echos[i].name, "expect results in the order of GetAll")
+	}
+
+	echos[2].setDelay(time.Millisecond * 300)
+	start := time.Now()
+	results, err = xc.Gather("Echo.Name", 0, &reply, 2, context.Background())
+	_assert(err == nil && time.Since(start) < time.Millisecond*200, "expect to return at the quorum, got %v after %s", err, time.Since(start))
+	_assert(results[2].Cancelled && results[2].Reply == nil, "expect the slow call to be cancelled, got %+v", results[2])
+	_assert(!results[0].Cancelled && !results[1].Cancelled, "expect completed calls not to be cancelled")
+
+	results, err = xc.Gather("Echo.Fail", 0, &reply, 2)
+	_assert(err != nil && len(results) == 3, "expect the quorum to fail")
+	failed := 0
+	for _, result := range results {
+		_assert(result.Err != nil, "expect every call to fail")
+		if !result.Cancelled {
+			failed++
+		}
+	}
+	_assert(failed >= 2, "expect failed calls not to be cancelled, got %d failed", failed)
+
+	empty := NewXClient(NewMultiServerDiscovery(nil), RandomSelect, nil)
+	_, err = empty.Gather("Echo.Name", 0, &reply, 0)
+	_assert(err != nil, "expect an error without servers")
+}
@@ -0,0 +1,135 @@
+package xclient
+
+import (
+	"context"
+	"hash/crc32"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// KeyedDiscovery is implemented by discoveries that can route by a per-call key,
+// which ConsistentHashSelect needs
+type KeyedDiscovery interface {
+	Discovery
+	GetByKey(mode SelectMode, key string) (string, error)
+}
+
+type HashOption struct {
+	VirtualNodes int     // points on the ring per server
+	LoadFactor   float64 // bounded load: no server takes more than LoadFactor times the average in-flight calls, 0 disables
+}
+
+var DefaultHashOption = &HashOption{
+	VirtualNodes: 160,
+}
+
+// hashRing maps keys to servers by consistent hashing with virtual nodes
+type hashRing struct {
+	hashes  []uint32
+	servers map[uint32]string
+}
+
+func newHashRing(servers []string, virtualNodes int) *hashRing {
+	if virtualNodes < 1 {
+		virtualNodes = 1
+	}
+	ring := &hashRing{servers: make(map[uint32]string, len(servers)*virtualNodes)}
+	for _, server := range servers {
+		for i := 0; i < virtualNodes; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + "#" + server))
+			if _, ok := ring.servers[hash]; ok {
+				continue
+			}
+			ring.servers[hash] = server
+			ring.hashes = append(ring.hashes, hash)
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+	return ring
+}
+
+// get walks the ring clockwise from key and returns the first server accepted
+func (ring *hashRing) get(key string, accept func(server string) bool) string {
+	hash := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(ring.hashes), func(i int) bool { return ring.hashes[i] >= hash })
+	first := ""
+	for i := 0; i < len(ring.hashes); i++ {
+		server := ring.servers[ring.hashes[(start+i)%len(ring.hashes)]]
+		if first == "" {
+			first = server
+		}
+		if accept(server) {
+			return server
+		}
+	}
+	return first
+}
+
+func (msd *MultiServerDiscovery) SetHashOption(opt *HashOption) {
+	if opt == nil {
+		opt = DefaultHashOption
+	}
+	msd.mux.Lock()
+	defer msd.mux.Unlock()
+	msd.hopt = opt
+	msd.ring = nil
+}
+
+// consistentHash builds the ring from all servers, so that keys of the
+// selectable servers stay put while others are temporarily ejected
+func (msd *MultiServerDiscovery) consistentHash(key string, servers []string) string {
+	if msd.ring == nil {
+		msd.ring = newHashRing(msd.servers, msd.hopt.VirtualNodes)
+	}
+	selectable := make(map[string]bool, len(servers))
+	total := 1 // the call about to be sent
+	for _, server := range servers {
+		selectable[server] = true
+		total += msd.inflight[server]
+	}
+	limit := int(math.Ceil(float64(total) / float64(len(servers)) * msd.hopt.LoadFactor))
+	server := msd.ring.get(key, func(server string) bool {
+		return selectable[server] && (msd.hopt.LoadFactor <= 0 || msd.inflight[server]+1 <= limit)
+	})
+	if !selectable[server] {
+		server = servers[0]
+	}
+	return server
+}
+
+func (msd *MultiServerDiscovery) GetByKey(mode SelectMode, key string) (string, error) {
+	msd.mux.Lock()
+	defer msd.mux.Unlock()
+	return msd.get(mode, key)
+}
+
+func (d *RegistryDiscovery) GetByKey(mode SelectMode, key string) (string, error) {
+	err := d.Refresh()
+	if err != nil {
+		return "", err
+	}
+	return d.MultiServerDiscovery.GetByKey(mode, key)
+}
+
+// RoutingKeyer is implemented by args that carry their own routing key
+type RoutingKeyer interface {
+	RoutingKey() string
+}
+
+type routingKey struct{}
+
+// WithRoutingKey sets the key ConsistentHashSelect routes a call by
+func WithRoutingKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, routingKey{}, key)
+}
+
+func routingKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(routingKey{}).(string)
+	return key
+}
+
+var (
+	_ KeyedDiscovery = (*MultiServerDiscovery)(nil)
+	_ KeyedDiscovery = (*RegistryDiscovery)(nil)
+)
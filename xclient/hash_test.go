@@ -0,0 +1,77 @@
+package xclient
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMultiServerDiscovery_ConsistentHash(t *testing.T) {
+	msd := NewMultiServerDiscovery([]string{"a", "b", "c"})
+	const keys = 1000
+	route := func() map[string]string {
+		routes := make(map[string]string, keys)
+		for i := 0; i < keys; i++ {
+			key := "key" + strconv.Itoa(i)
+			routes[key], _ = msd.get(ConsistentHashSelect, key)
+		}
+		return routes
+	}
+	before := route()
+	for key, server := range route() {
+		_assert(before[key] == server, "expect %s to stay on %s", key, before[key])
+	}
+
+	cases := []struct {
+		name    string
+		change  func()
+		to      string // keys that move, move there, "" for anywhere
+		from    string // keys that move, move from there, "" for anywhere
+		maxMove int
+	}{
+		{"add", func() { _ = msd.Update([]string{"a", "b", "c", "d"}) }, "d", "", keys / 2},
+		{"remove", func() { _ = msd.Update([]string{"a", "b", "c"}) }, "", "d", keys / 2},
+		{"eject", func() { msd.Eject("b", time.Now().Add(time.Minute)) }, "", "b", keys / 2},
+		{"reinstate", func() { msd.Reinstate("b") }, "b", "", keys / 2},
+	}
+	for _, c := range cases {
+		c.change()
+		after := route()
+		moved := 0
+		for key, server := range after {
+			if before[key] == server {
+				continue
+			}
+			moved++
+			_assert(c.to == "" || server == c.to, "%s: expect %s to move to %s, got %s", c.name, key, c.to, server)
+			_assert(c.from == "" || before[key] == c.from, "%s: expect only keys of %s to move, %s moved from %s", c.name, c.from, key, before[key])
+		}
+		_assert(moved > 0 && moved < c.maxMove, "%s: expect some keys to move, %d moved", c.name, moved)
+		before = after
+	}
+
+	// no key, any server
+	server, err := msd.get(ConsistentHashSelect, "")
+	_assert(err == nil && server != "", "expect a server without a key, got %v", err)
+}
+
+func TestMultiServerDiscovery_BoundedLoad(t *testing.T) {
+	cases := []struct {
+		loadFactor float64
+		inflight   int
+		moves      bool
+	}{
+		{0, 10, false},   // bounded load disabled
+		{1.25, 0, false}, // idle, the bound is 1
+		{1.25, 10, true}, // 11 calls, the bound is ceil(11/3*1.25) = 5
+		{10, 10, false},  // bound of 37
+	}
+	for _, c := range cases {
+		msd := NewMultiServerDiscovery([]string{"a", "b", "c"})
+		msd.SetHashOption(&HashOption{VirtualNodes: 160, LoadFactor: c.loadFactor})
+		home, _ := msd.get(ConsistentHashSelect, "user-1")
+		msd.inflight[home] = c.inflight
+		server, _ := msd.get(ConsistentHashSelect, "user-1")
+		_assert((server != home) == c.moves, "load factor %g, %d in flight: expect moved %v, got %s from %s", c.loadFactor, c.inflight, c.moves, server, home)
+	}
+}
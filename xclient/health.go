@@ -0,0 +1,288 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	. "github.com/vlzx/zrpc"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ejector is implemented by discoveries that can temporarily leave a server
+// out of selection. Ejected servers are still returned by GetAll.
+type Ejector interface {
+	Eject(rpcAddr string, until time.Time)
+	Reinstate(rpcAddr string)
+}
+
+var _ Ejector = (*MultiServerDiscovery)(nil)
+
+func (msd *MultiServerDiscovery) Eject(rpcAddr string, until time.Time) {
+	msd.mux.Lock()
+	defer msd.mux.Unlock()
+	msd.ejected[rpcAddr] = until
+}
+
+func (msd *MultiServerDiscovery) Reinstate(rpcAddr string) {
+	msd.mux.Lock()
+	defer msd.mux.Unlock()
+	delete(msd.ejected, rpcAddr)
+}
+
+// selectable returns the servers that are not ejected, or all of them if
+// every server is ejected, as sending somewhere beats failing every call
+func (msd *MultiServerDiscovery) selectable() []string {
+	if len(msd.ejected) == 0 {
+		return msd.servers
+	}
+	now := time.Now()
+	servers := make([]string, 0, len(msd.servers))
+	for _, server := range msd.servers {
+		until, ok := msd.ejected[server]
+		if ok && now.Before(until) {
+			continue
+		}
+		if ok {
+			delete(msd.ejected, server)
+		}
+		servers = append(servers, server)
+	}
+	if len(servers) == 0 {
+		return msd.servers
+	}
+	return servers
+}
+
+// ProbeFunc checks whether the server at rpcAddr (protocol@addr) is healthy
+type ProbeFunc func(ctx context.Context, rpcAddr string) error
+
+// TCPProbe only checks that the server accepts connections
+func TCPProbe(ctx context.Context, rpcAddr string) error {
+	tokens := strings.Split(rpcAddr, "@")
+	if len(tokens) != 2 {
+		return fmt.Errorf("rpc health: wrong zRPC address format '%s', expect protocol@addr", rpcAddr)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", tokens[1])
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// RPCProbe calls serviceMethod, e.g. "zrpc.Health.Check", with an empty service
+// name, and expects a nil error and a status of "SERVING" or ""
+func RPCProbe(serviceMethod string) ProbeFunc {
+	return func(ctx context.Context, rpcAddr string) error {
+		opt := &Option{ConnectTimeout: 0}
+		if deadline, ok := ctx.Deadline(); ok {
+			opt.ConnectTimeout = time.Until(deadline)
+		}
+		client, err := XDial(rpcAddr, opt)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = client.Close() }()
+		var status string
+		if err := client.Call(serviceMethod, "", &status, ctx); err != nil {
+			return err
+		}
+		if status != "" && status != "SERVING" {
+			return errors.New("rpc health: server status " + status)
+		}
+		return nil
+	}
+}
+
+type HealthCheckOption struct {
+	Interval     time.Duration // between two probes of a server
+	Timeout      time.Duration // of a single probe
+	Probe        ProbeFunc     // TCPProbe if not set
+	MaxFailures  int           // consecutive failed probes before a server is ejected
+	BaseEjection time.Duration // ejection time, doubled every time a server is ejected again
+	MaxEjection  time.Duration
+}
+
+var DefaultHealthCheckOption = &HealthCheckOption{
+	Interval:     time.Second * 5,
+	Timeout:      time.Second,
+	MaxFailures:  3,
+	BaseEjection: time.Second * 30,
+	MaxEjection:  time.Minute * 5,
+}
+
+type endpointHealth struct {
+	failures     int // consecutive failed probes
+	ejections    int // drives the ejection backoff, decreases with healthy probes
+	ejectedUntil time.Time
+}
+
+// HealthChecker periodically probes every server of a discovery, and ejects
+// the ones that keep failing from selection
+type HealthChecker struct {
+	d      Discovery
+	e      Ejector
+	opt    *HealthCheckOption
+	mux    sync.Mutex // protect following
+	health map[string]*endpointHealth
+	stop   chan struct{} // nil once closed
+}
+
+var _ io.Closer = (*HealthChecker)(nil)
+
+// NewHealthChecker starts probing right away, d has to implement Ejector
+// as MultiServerDiscovery and RegistryDiscovery do. Fields of opt left at
+// zero take the value of DefaultHealthCheckOption, Probe is TCPProbe then.
+func NewHealthChecker(d Discovery, opt *HealthCheckOption) (*HealthChecker, error) {
+	e, ok := d.(Ejector)
+	if !ok {
+		return nil, errors.New("rpc health: discovery does not support ejection")
+	}
+	if opt == nil {
+		opt = DefaultHealthCheckOption
+	}
+	copied := *opt
+	if copied.Probe == nil {
+		copied.Probe = TCPProbe
+	}
+	if copied.Interval <= 0 {
+		copied.Interval = DefaultHealthCheckOption.Interval
+	}
+	if copied.Timeout <= 0 {
+		copied.Timeout = DefaultHealthCheckOption.Timeout
+	}
+	if copied.MaxFailures <= 0 {
+		copied.MaxFailures = DefaultHealthCheckOption.MaxFailures
+	}
+	if copied.BaseEjection <= 0 {
+		copied.BaseEjection = DefaultHealthCheckOption.BaseEjection
+	}
+	if copied.MaxEjection <= 0 {
+		copied.MaxEjection = DefaultHealthCheckOption.MaxEjection
+	}
+	hc := &HealthChecker{
+		d:      d,
+		e:      e,
+		opt:    &copied,
+		health: make(map[string]*endpointHealth),
+		stop:   make(chan struct{}),
+	}
+	go hc.run(hc.stop)
+	return hc, nil
+}
+
+func (hc *HealthChecker) run(stop chan struct{}) {
+	t := time.NewTicker(hc.opt.Interval)
+	defer t.Stop()
+	for {
+		hc.probeAll()
+		select {
+		case <-t.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (hc *HealthChecker) probeAll() {
+	servers, err := hc.d.GetAll()
+	if err != nil {
+		log.Println("rpc health: get servers error:", err)
+		return
+	}
+	var wg sync.WaitGroup
+	for _, rpcAddr := range servers {
+		wg.Add(1)
+		go func(rpcAddr string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), hc.opt.Timeout)
+			defer cancel()
+			hc.report(rpcAddr, hc.opt.Probe(ctx, rpcAddr))
+		}(rpcAddr)
+	}
+	wg.Wait()
+}
+
+func (hc *HealthChecker) report(rpcAddr string, err error) {
+	hc.mux.Lock()
+	defer hc.mux.Unlock()
+	if hc.stop == nil {
+		return // closed while probing
+	}
+	h, ok := hc.health[rpcAddr]
+	if !ok {
+		h = new(endpointHealth)
+		hc.health[rpcAddr] = h
+	}
+	now := time.Now()
+	ejected := now.Before(h.ejectedUntil)
+	if err == nil {
+		h.failures = 0
+		if ejected {
+			// recovered early, ejections stays up so that a flapping server is ejected for longer
+			h.ejectedUntil = time.Time{}
+			log.Printf("rpc health: reinstate %s", rpcAddr)
+			hc.e.Reinstate(rpcAddr)
+		} else if h.ejections > 0 {
+			h.ejections--
+		}
+		return
+	}
+	h.failures++
+	if ejected || h.failures < hc.opt.MaxFailures {
+		return
+	}
+	backoff := hc.opt.BaseEjection << h.ejections
+	if backoff > hc.opt.MaxEjection || backoff <= 0 {
+		backoff = hc.opt.MaxEjection
+	}
+	h.ejections++
+	h.failures = 0
+	h.ejectedUntil = now.Add(backoff)
+	log.Printf("rpc health: eject %s for %s: %s", rpcAddr, backoff, err)
+	hc.e.Eject(rpcAddr, h.ejectedUntil)
+}
+
+// Status returns the health of every probed server,
+// e.g. to be shown on the debug page by zrpc.Server.AddDebugSection
+func (hc *HealthChecker) Status() map[string]string {
+	hc.mux.Lock()
+	defer hc.mux.Unlock()
+	now := time.Now()
+	status := make(map[string]string, len(hc.health))
+	for rpcAddr, h := range hc.health {
+		switch {
+		case now.Before(h.ejectedUntil):
+			status[rpcAddr] = "ejected until " + h.ejectedUntil.Format(time.RFC3339)
+		case h.failures > 0:
+			status[rpcAddr] = fmt.Sprintf("healthy, %d failed probes", h.failures)
+		default:
+			status[rpcAddr] = "healthy"
+		}
+	}
+	return status
+}
+
+// Close stops probing and reinstates the servers it ejected
+func (hc *HealthChecker) Close() error {
+	hc.mux.Lock()
+	defer hc.mux.Unlock()
+	if hc.stop == nil {
+		return nil
+	}
+	close(hc.stop)
+	hc.stop = nil
+	now := time.Now()
+	for rpcAddr, h := range hc.health {
+		if now.Before(h.ejectedUntil) {
+			h.ejectedUntil = time.Time{}
+			hc.e.Reinstate(rpcAddr)
+		}
+	}
+	return nil
+}
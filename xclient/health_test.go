@@ -0,0 +1,61 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func (msd *MultiServerDiscovery) isEjected(server string) bool {
+	msd.mux.Lock()
+	defer msd.mux.Unlock()
+	for _, s := range msd.selectable() {
+		if s == server {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHealthChecker_Defaults(t *testing.T) {
+	d := NewMultiServerDiscovery(nil)
+	hc, err := NewHealthChecker(d, &HealthCheckOption{MaxFailures: 1})
+	_assert(err == nil, "health checker error: %v", err)
+	opt, def := hc.opt, DefaultHealthCheckOption
+	_assert(opt.Probe != nil && opt.MaxFailures == 1, "expect TCPProbe and the given MaxFailures")
+	_assert(opt.Interval == def.Interval && opt.Timeout == def.Timeout && opt.BaseEjection == def.BaseEjection && opt.MaxEjection == def.MaxEjection,
+		"expect defaults for fields left at zero, got %+v", opt)
+	_assert(hc.Close() == nil && hc.Close() == nil, "expect Close to be idempotent")
+}
+
+func TestHealthChecker(t *testing.T) {
+	d := NewMultiServerDiscovery([]string{"a", "b", "c"})
+	var failing atomic.Value
+	failing.Store("b")
+	probe := func(ctx context.Context, rpcAddr string) error {
+		if rpcAddr == failing.Load().(string) {
+			return errors.New("unhealthy")
+		}
+		return nil
+	}
+	hc, _ := NewHealthChecker(d, &HealthCheckOption{Interval: time.Millisecond * 10, Probe: probe, MaxFailures: 2, BaseEjection: time.Minute})
+	defer func() { _ = hc.Close() }()
+
+	_assert(waitFor(func() bool { return d.isEjected("b") }), "expect b to be ejected")
+	_assert(!d.isEjected("a") && !d.isEjected("c"), "expect healthy servers to stay")
+	_assert(strings.HasPrefix(hc.Status()["b"], "ejected until"), "expect the ejection in the status, got %q", hc.Status()["b"])
+	servers, _ := d.GetAll()
+	_assert(len(servers) == 3, "expect GetAll to return ejected servers")
+
+	failing.Store("")
+	_assert(waitFor(func() bool { return !d.isEjected("b") }), "expect b to be reinstated once healthy")
+	_assert(hc.Status()["b"] == "healthy", "expect b to be healthy, got %q", hc.Status()["b"])
+
+	failing.Store("c")
+	_assert(waitFor(func() bool { return d.isEjected("c") }), "expect c to be ejected")
+	_ = hc.Close()
+	_assert(!d.isEjected("c"), "expect Close to reinstate c")
+}
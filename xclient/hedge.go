@@ -0,0 +1,179 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HedgePolicy sends the same request to another server when the first one
+// is slow, the first successful reply wins. Only use it for idempotent methods.
+type HedgePolicy struct {
+	Delay     time.Duration // wait this long before each hedged request
+	UseP95    bool          // wait for the observed p95 latency of the method instead, Delay until enough samples exist
+	MaxHedges int           // hedged requests sent besides the original one, 1 if not set
+}
+
+var DefaultHedgePolicy = &HedgePolicy{
+	Delay:     time.Millisecond * 100,
+	MaxHedges: 1,
+}
+
+var errAllTried = errors.New("rpc client: every server has been tried")
+
+const (
+	latencySamples    = 128
+	minLatencySamples = 20
+)
+
+// latencyWindow keeps the latest successful call latencies of a method
+type latencyWindow struct {
+	mux     sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if len(w.samples) < latencySamples {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % latencySamples
+}
+
+// percentile returns false if there are not enough samples yet
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if len(w.samples) < minLatencySamples {
+		return 0, false
+	}
+	sorted := make([]time.Duration, len(w.samples))
+	copy(sorted, w.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(float64(len(sorted)-1)*p)], true
+}
+
+// SetHedgePolicy enables hedging for "Service.Method", or "Service" for all
+// of its methods. A nil policy disables it again. Fields left at zero take
+// the value of DefaultHedgePolicy, a Delay of 0 would hedge every call at once.
+func (xc *XClient) SetHedgePolicy(name string, policy *HedgePolicy) {
+	xc.mux.Lock()
+	defer xc.mux.Unlock()
+	if policy == nil {
+		delete(xc.hedges, name)
+		return
+	}
+	copied := *policy
+	if copied.Delay <= 0 {
+		copied.Delay = DefaultHedgePolicy.Delay
+	}
+	if copied.MaxHedges <= 0 {
+		copied.MaxHedges = DefaultHedgePolicy.MaxHedges
+	}
+	xc.hedges[name] = &copied
+}
+
+func (xc *XClient) hedgePolicy(serviceMethod string) *HedgePolicy {
+	xc.mux.Lock()
+	defer xc.mux.Unlock()
+	if policy, ok := xc.hedges[serviceMethod]; ok {
+		return policy
+	}
+	if dot := strings.LastIndex(serviceMethod, "."); dot > 0 {
+		return xc.hedges[serviceMethod[:dot]]
+	}
+	return nil
+}
+
+func (xc *XClient) latencies(serviceMethod string) *latencyWindow {
+	xc.mux.Lock()
+	defer xc.mux.Unlock()
+	w, ok := xc.latency[serviceMethod]
+	if !ok {
+		w = new(latencyWindow)
+		xc.latency[serviceMethod] = w
+	}
+	return w
+}
+
+type hedgeResult struct {
+	reply   interface{}
+	err     error
+	latency time.Duration
+}
+
+func (xc *XClient) callWithHedge(policy *HedgePolicy, serviceMethod string, args interface{}, reply interface{}, ctx context.Context) error {
+	window := xc.latencies(serviceMethod)
+	delay := policy.Delay
+	if policy.UseP95 {
+		if p95, ok := window.percentile(0.95); ok {
+			delay = p95
+		}
+	}
+	maxHedges := policy.MaxHedges
+	if maxHedges < 1 {
+		maxHedges = 1
+	}
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel() // the first successful reply cancels the rest
+	results := make(chan hedgeResult, maxHedges+1)
+	tried := make(map[string]bool)
+	send := func() error {
+		rpcAddr, err := xc.pick(ctx, tried)
+		if err != nil {
+			return err
+		}
+		if tried[rpcAddr] {
+			return errAllTried // hedging the same server again only doubles its load
+		}
+		tried[rpcAddr] = true
+		var clonedReply interface{}
+		if reply != nil {
+			clonedReply = reflect.New(reflect.ValueOf(reply).Elem().Type()).Interface()
+		}
+		go func() {
+			start := time.Now()
+			err := xc.call(rpcAddr, serviceMethod, args, clonedReply, hedgeCtx)
+			results <- hedgeResult{reply: clonedReply, err: err, latency: time.Since(start)}
+		}()
+		return nil
+	}
+	if err := send(); err != nil {
+		return err
+	}
+	inflight, hedges := 1, 0
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var e error
+	for inflight > 0 {
+		select {
+		case <-timer.C:
+			if hedges < maxHedges && send() == nil {
+				inflight++
+				hedges++
+				timer.Reset(delay)
+			}
+		case result := <-results:
+			inflight--
+			if result.err == nil {
+				window.add(result.latency)
+				if reply != nil {
+					reflect.ValueOf(reply).Elem().Set(reflect.ValueOf(result.reply).Elem())
+				}
+				return nil
+			}
+			if e == nil {
+				e = result.err
+			}
+		}
+	}
+	return e
+}
@@ -0,0 +1,68 @@
+package xclient
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLatencyWindow(t *testing.T) {
+	w := new(latencyWindow)
+	for i := 1; i < minLatencySamples; i++ {
+		w.add(time.Millisecond)
+	}
+	_, ok := w.percentile(0.95)
+	_assert(!ok, "expect no percentile before %d samples", minLatencySamples)
+	for i := 1; i <= latencySamples; i++ {
+		w.add(time.Duration(i) * time.Millisecond)
+	}
+	p95, ok := w.percentile(0.95)
+	_assert(ok && p95 == time.Millisecond*121, "expect p95 of the latest %d samples, got %s", latencySamples, p95)
+}
+
+func TestXClient_Hedge(t *testing.T) {
+	addrs, echos, stop := startServers(2)
+	defer stop()
+	xc := NewXClient(NewMultiServerDiscovery(addrs), RoundRobinSelect, nil)
+	defer func() { _ = xc.Close() }()
+	xc.SetHedgePolicy("Echo.Name", &HedgePolicy{Delay: time.Millisecond * 20, MaxHedges: 3})
+
+	// whichever server is tried first, the fast one replies
+	echos[0].setDelay(time.Millisecond * 300)
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		var reply string
+		err := xc.Call("Echo.Name", 0, &reply, context.Background())
+		_assert(err == nil && reply == "1" && time.Since(start) < time.Millisecond*200,
+			"expect the reply of the fast server, got %q %v after %s", reply, err, time.Since(start))
+	}
+
+	// no hedge goes to a server already tried
+	echos[0].setDelay(time.Millisecond * 100)
+	echos[1].setDelay(time.Millisecond * 100)
+	time.Sleep(time.Millisecond * 300)
+	calls := echos[0].numCalls() + echos[1].numCalls()
+	var reply string
+	_ = xc.Call("Echo.Name", 0, &reply, context.Background())
+	time.Sleep(time.Millisecond * 150)
+	calls = echos[0].numCalls() + echos[1].numCalls() - calls
+	_assert(calls == 2, "expect one call per server, got %d", calls)
+}
+
+func TestXClient_HedgeDefaultDelay(t *testing.T) {
+	addrs, echos, stop := startServers(2)
+	defer stop()
+	xc := NewXClient(NewMultiServerDiscovery(addrs), RoundRobinSelect, nil)
+	defer func() { _ = xc.Close() }()
+	xc.SetHedgePolicy("Echo", &HedgePolicy{UseP95: true})
+	_assert(*xc.hedgePolicy("Echo.Name") == HedgePolicy{Delay: DefaultHedgePolicy.Delay, UseP95: true, MaxHedges: 1},
+		"expect defaults for fields left at zero, got %+v", xc.hedgePolicy("Echo.Name"))
+
+	// no p95 yet, the call is not hedged right away
+	var reply string
+	err := xc.Call("Echo.Name", 0, &reply, context.Background())
+	_assert(err == nil, "call error: %v", err)
+	time.Sleep(time.Millisecond * 50)
+	calls := echos[0].numCalls() + echos[1].numCalls()
+	_assert(calls == 1, "expect a single call, got %d", calls)
+}
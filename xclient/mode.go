@@ -0,0 +1,74 @@
+package xclient
+
+import (
+	"context"
+)
+
+type CallMode int
+
+const (
+	Failfast CallMode = iota // never retry
+	Failover                 // try the next server on a connection error or when overloaded
+	Failtry                  // retry the same server on a connection error
+	Forking                  // send to several servers at once, the first success wins
+)
+
+type CallOption struct {
+	Mode    CallMode
+	Retries int // retries of Failover and Failtry, 2 if not set
+	Forks   int // servers a Forking call goes to, 2 if not set
+}
+
+var DefaultCallOption = &CallOption{
+	Mode: Failfast,
+}
+
+func (copt *CallOption) retries() int {
+	if copt.Retries < 1 {
+		return 2
+	}
+	return copt.Retries
+}
+
+func (copt *CallOption) forks() int {
+	if copt.Forks < 1 {
+		return 2
+	}
+	return copt.Forks
+}
+
+type callOptionKey struct{}
+
+// WithCallOption selects the call mode of a single XClient.Call, overriding the
+// mode of the XClient as well as the retry and hedge policies of the method
+func WithCallOption(ctx context.Context, copt *CallOption) context.Context {
+	return context.WithValue(ctx, callOptionKey{}, copt)
+}
+
+func callOptionFromContext(ctx context.Context) (*CallOption, bool) {
+	copt, ok := ctx.Value(callOptionKey{}).(*CallOption)
+	return copt, ok && copt != nil
+}
+
+func (xc *XClient) callWithMode(copt *CallOption, serviceMethod string, args interface{}, reply interface{}, ctx context.Context) error {
+	switch copt.Mode {
+	case Failover:
+		policy := &RetryPolicy{MaxAttempts: copt.retries() + 1, RetryOn: ErrClassConn | ErrClassOverload}
+		return xc.callWithRetry(policy, serviceMethod, args, reply, ctx)
+	case Forking:
+		policy := &HedgePolicy{MaxHedges: copt.forks() - 1}
+		return xc.callWithHedge(policy, serviceMethod, args, reply, ctx)
+	}
+	rpcAddr, err := xc.pick(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = xc.call(rpcAddr, serviceMethod, args, reply, ctx)
+	if copt.Mode != Failtry {
+		return err
+	}
+	for i := 0; i < copt.retries() && classify(err)&ErrClassConn != 0 && ctx.Err() == nil; i++ {
+		err = xc.call(rpcAddr, serviceMethod, args, reply, ctx)
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package xclient
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestXClient_CallMode(t *testing.T) {
+	addrs, echos, stop := startServers(2)
+	defer stop()
+	const dead = "tcp@127.0.0.1:1"
+	xc := NewXClient(NewMultiServerDiscovery([]string{dead, addrs[0]}), RoundRobinSelect, nil)
+	defer func() { _ = xc.Close() }()
+	failures := func(copt *CallOption, n int) (failed int) {
+		ctx := WithCallOption(context.Background(), copt)
+		for i := 0; i < n; i++ {
+			var reply string
+			if err := xc.Call("Echo.Name", 0, &reply, ctx); err != nil {
+				failed++
+			}
+		}
+		return
+	}
+	cases := []struct {
+		copt   *CallOption
+		failed int
+	}{
+		{&CallOption{Mode: Failfast}, 2},
+		{&CallOption{Mode: Failtry, Retries: 2}, 2}, // the dead server is tried again
+		{&CallOption{Mode: Failover}, 0},
+	}
+	for _, c := range cases {
+		failed := failures(c.copt, 4)
+		_assert(failed == c.failed, "mode %d: expect %d of 4 calls to fail, got %d", c.copt.Mode, c.failed, failed)
+	}
+
+	forking := NewXClient(NewMultiServerDiscovery(addrs), RoundRobinSelect, nil)
+	defer func() { _ = forking.Close() }()
+	echos[0].setDelay(time.Millisecond * 300)
+	calls := echos[0].numCalls()
+	ctx := WithCallOption(context.Background(), &CallOption{Mode: Forking})
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		var reply string
+		err := forking.Call("Echo.Name", 0, &reply, ctx)
+		_assert(err == nil && reply == "1" && time.Since(start) < time.Millisecond*200,
+			"expect the reply of the fast server, got %q %v after %s", reply, err, time.Since(start))
+	}
+	_assert(waitFor(func() bool { return echos[0].numCalls()-calls == 2 }), "expect every call forked to both servers, got %d", echos[0].numCalls()-calls)
+}
@@ -0,0 +1,196 @@
+package xclient
+
+import (
+	. "github.com/vlzx/zrpc"
+	"sync"
+	"time"
+)
+
+// PoolOption configures the connections XClient keeps per server address
+type PoolOption struct {
+	MinSize     int           // connections kept open even when idle
+	MaxSize     int           // upper bound of connections, 1 if not set
+	IdleTimeout time.Duration // close connections idle for longer, 0 means never
+}
+
+var DefaultPoolOption = &PoolOption{
+	MinSize: 0,
+	MaxSize: 1,
+}
+
+type pooledClient struct {
+	*Client
+	lastUsed time.Time
+}
+
+type clientPool struct {
+	rpcAddr string
+	opt     *Option
+	popt    *PoolOption
+	mux     sync.Mutex // protect following
+	clients []*pooledClient
+	dialing int           // connections being dialed
+	dialed  chan struct{} // closed whenever a dial completes
+	dialErr error         // of the last dial
+	closed  bool
+}
+
+func newClientPool(rpcAddr string, opt *Option, popt *PoolOption) *clientPool {
+	return &clientPool{
+		rpcAddr: rpcAddr,
+		opt:     opt,
+		popt:    popt,
+		dialed:  make(chan struct{}),
+	}
+}
+
+func (p *clientPool) maxSize() int {
+	if p.popt.MaxSize < 1 {
+		return 1
+	}
+	return p.popt.MaxSize
+}
+
+// size counts connections being dialed as well
+func (p *clientPool) size() int {
+	return len(p.clients) + p.dialing
+}
+
+// get returns the connection with the least pending calls, and dials a new one
+// if all are busy and the pool is not full yet. Connections up to MinSize are
+// dialed in the background.
+func (p *clientPool) get() (*Client, error) {
+	for {
+		p.mux.Lock()
+		if p.closed {
+			p.mux.Unlock()
+			return nil, ErrShutdown
+		}
+		p.removeUnavailable()
+		p.evictIdle(time.Now())
+		least := p.leastPending()
+		if least == nil && p.size() >= p.maxSize() {
+			// wait for the connection being dialed instead of exceeding MaxSize
+			wait := p.dialed
+			p.mux.Unlock()
+			<-wait
+			p.mux.Lock()
+			err := p.dialErr
+			p.mux.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		dial := least == nil || (least.NumPending() > 0 && p.size() < p.maxSize())
+		if dial {
+			p.dialing++
+		}
+		for p.size() < p.popt.MinSize && p.size() < p.maxSize() {
+			p.dialing++
+			go func() { _, _ = p.dial() }()
+		}
+		if !dial {
+			least.lastUsed = time.Now()
+			p.mux.Unlock()
+			return least.Client, nil
+		}
+		p.mux.Unlock()
+		pc, err := p.dial()
+		if err == nil {
+			return pc.Client, nil
+		}
+		if least == nil {
+			return nil, err
+		}
+		return least.Client, nil
+	}
+}
+
+// leastPending is called with p.mux held
+func (p *clientPool) leastPending() *pooledClient {
+	var least *pooledClient
+	leastPending := 0
+	for _, pc := range p.clients {
+		pending := pc.NumPending()
+		if least == nil || pending < leastPending {
+			least, leastPending = pc, pending
+		}
+	}
+	return least
+}
+
+// dial connects without holding p.mux, so that calls are not held up by a
+// server that is slow to connect. p.dialing has to be incremented before.
+func (p *clientPool) dial() (*pooledClient, error) {
+	client, err := XDial(p.rpcAddr, p.opt)
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.dialing--
+	p.dialErr = err
+	close(p.dialed)
+	p.dialed = make(chan struct{})
+	if err != nil {
+		return nil, err
+	}
+	if p.closed {
+		_ = client.Close()
+		return nil, ErrShutdown
+	}
+	pc := &pooledClient{Client: client, lastUsed: time.Now()}
+	p.clients = append(p.clients, pc)
+	return pc, nil
+}
+
+func (p *clientPool) removeUnavailable() {
+	clients := p.clients[:0]
+	for _, pc := range p.clients {
+		if pc.IsAvailable() {
+			clients = append(clients, pc)
+		} else {
+			_ = pc.Close()
+		}
+	}
+	p.clients = clients
+}
+
+func (p *clientPool) evictIdle(now time.Time) {
+	if p.popt.IdleTimeout <= 0 {
+		return
+	}
+	clients := p.clients[:0]
+	for i, pc := range p.clients {
+		kept := len(p.clients) - i + len(clients)
+		if kept > p.popt.MinSize && pc.lastUsed.Add(p.popt.IdleTimeout).Before(now) && pc.NumPending() == 0 {
+			_ = pc.Close()
+			continue
+		}
+		clients = append(clients, pc)
+	}
+	p.clients = clients
+}
+
+func (p *clientPool) setOption(popt *PoolOption) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.popt = popt
+}
+
+// evict closes broken and idle connections of pools that are not used anymore
+func (p *clientPool) evict() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.removeUnavailable()
+	p.evictIdle(time.Now())
+}
+
+func (p *clientPool) Close() error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for _, pc := range p.clients {
+		_ = pc.Close()
+	}
+	p.clients = nil
+	p.closed = true
+	return nil
+}
@@ -0,0 +1,114 @@
+package xclient
+
+import (
+	"context"
+	. "github.com/vlzx/zrpc"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func (p *clientPool) numClients() int {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return len(p.clients)
+}
+
+func waitFor(cond func() bool) bool {
+	for deadline := time.Now().Add(time.Second * 2); time.Now().Before(deadline); time.Sleep(time.Millisecond * 5) {
+		if cond() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestClientPool(t *testing.T) {
+	echo := &Echo{name: "0"}
+	addr, server := startServer(echo)
+	defer server.Shutdown()
+	p := newClientPool(addr, DefaultOption, &PoolOption{MinSize: 2, MaxSize: 3, IdleTimeout: time.Millisecond * 100})
+	defer func() { _ = p.Close() }()
+
+	c1, err := p.get()
+	_assert(err == nil, "get error: %v", err)
+	_assert(waitFor(func() bool { return p.numClients() == 2 }), "expect MinSize connections, got %d", p.numClients())
+
+	echo.setDelay(time.Millisecond * 200)
+	var wg sync.WaitGroup
+	busy := func(c *Client) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var reply string
+			_ = c.Call("Echo.Name", 0, &reply)
+		}()
+		_assert(waitFor(func() bool { return c.NumPending() == 1 }), "expect a pending call")
+	}
+	busy(c1)
+	c2, _ := p.get()
+	_assert(c2 != c1, "expect the idle connection")
+	busy(c2)
+	c3, _ := p.get()
+	_assert(c3 != c1 && c3 != c2 && p.numClients() == 3, "expect a new connection while all are busy")
+	busy(c3)
+	c4, _ := p.get()
+	_assert(c4 == c1 || c4 == c2 || c4 == c3, "expect no more than MaxSize connections")
+	_assert(p.numClients() == 3, "expect MaxSize connections, got %d", p.numClients())
+	wg.Wait()
+
+	time.Sleep(time.Millisecond * 150)
+	p.evict()
+	_assert(p.numClients() == 2, "expect idle connections to be closed down to MinSize, got %d", p.numClients())
+}
+
+func TestClientPool_SlowDial(t *testing.T) {
+	// accepts connections but never answers the CONNECT of http@
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer func() { _ = l.Close() }()
+	go func() {
+		var conns []net.Conn
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				break
+			}
+			conns = append(conns, conn)
+		}
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+	p := newClientPool("http@"+l.Addr().String(), &Option{MagicNumber: MagicNumber, ConnectTimeout: time.Millisecond * 200}, &PoolOption{MaxSize: 1})
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.get()
+			_assert(err != nil, "expect a connect timeout")
+		}()
+	}
+	wg.Wait()
+	_assert(time.Since(start) < time.Millisecond*500, "expect waiting calls to share a dial, took %s", time.Since(start))
+}
+
+func TestXClient_PrunePools(t *testing.T) {
+	addrs, _, stop := startServers(2)
+	defer stop()
+	d := NewMultiServerDiscovery(addrs)
+	xc := NewXClient(d, RoundRobinSelect, nil)
+	defer func() { _ = xc.Close() }()
+	var reply string
+	for i := 0; i < 2; i++ {
+		_ = xc.Call("Echo.Name", 0, &reply, context.Background())
+	}
+	_assert(len(xc.pools) == 2, "expect a pool per server, got %d", len(xc.pools))
+	_ = d.Update(addrs[1:])
+	_ = xc.Call("Echo.Name", 0, &reply, context.Background())
+	xc.prunePools()
+	_, ok := xc.pools[addrs[0]]
+	_assert(len(xc.pools) == 1 && !ok, "expect the pool of the removed server to be closed")
+}
@@ -0,0 +1,167 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	. "github.com/vlzx/zrpc"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrorClass is a bit set of error kinds a RetryPolicy retries on
+type ErrorClass int
+
+const (
+	ErrClassConn      ErrorClass = 1 << iota // dial failures, broken connections including ErrShutdown, and ErrBreakerOpen
+	ErrClassTimeout                          // client side deadline or server side handle timeout
+	ErrClassServer                           // any other error returned by the remote method
+	ErrClassOverload                         // rejected by a concurrency limit of the server without being executed
+	ErrClassRateLimit                        // rejected by a rate limit of the server without being executed
+)
+
+// classify tells which class err belongs to, 0 for nil or unknown errors
+func classify(err error) ErrorClass {
+	var serverErr ServerError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrClassTimeout
+	case IsOverloaded(err):
+		return ErrClassOverload
+	case IsRateLimited(err):
+		return ErrClassRateLimit
+	case errors.As(err, &serverErr):
+		if strings.Contains(string(serverErr), "handle request timeout") {
+			return ErrClassTimeout
+		}
+		return ErrClassServer
+	case errors.Is(err, ErrBreakerOpen), errors.Is(err, ErrShutdown), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, io.ErrClosedPipe), strings.Contains(err.Error(), "connect timeout"):
+		return ErrClassConn
+	case errors.As(err, &netErr):
+		return ErrClassConn
+	}
+	return 0
+}
+
+// unhealthy tells whether err means the server could not serve the call,
+// errors returned by the remote method mean it is healthy
+func unhealthy(err error) bool {
+	return classify(err)&(ErrClassConn|ErrClassTimeout|ErrClassOverload) != 0
+}
+
+// RetryPolicy is only safe for idempotent methods, as a request that failed
+// on the client side may still have been executed by the server
+type RetryPolicy struct {
+	MaxAttempts   int           // including the first attempt
+	PerTryTimeout time.Duration // 0 means only the caller's context applies
+	Backoff       time.Duration // wait before the second attempt, doubled after each one
+	MaxBackoff    time.Duration
+	RetryOn       ErrorClass
+}
+
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     time.Millisecond * 100,
+	MaxBackoff:  time.Second,
+	RetryOn:     ErrClassConn | ErrClassTimeout | ErrClassOverload,
+}
+
+// SetRetryPolicy opts a method in as idempotent, name is either "Service.Method"
+// or "Service" for all of its methods. A nil policy opts it out again.
+func (xc *XClient) SetRetryPolicy(name string, policy *RetryPolicy) {
+	xc.mux.Lock()
+	defer xc.mux.Unlock()
+	if policy == nil {
+		delete(xc.retries, name)
+		return
+	}
+	xc.retries[name] = policy
+}
+
+func (xc *XClient) retryPolicy(serviceMethod string) *RetryPolicy {
+	xc.mux.Lock()
+	defer xc.mux.Unlock()
+	if policy, ok := xc.retries[serviceMethod]; ok {
+		return policy
+	}
+	if dot := strings.LastIndex(serviceMethod, "."); dot > 0 {
+		return xc.retries[serviceMethod[:dot]]
+	}
+	return nil
+}
+
+// pick selects a server by mode, preferring one that has not been tried yet
+// and whose circuit breaker is not open
+func (xc *XClient) pick(ctx context.Context, tried map[string]bool) (string, error) {
+	var rpcAddr string
+	var err error
+	if kd, ok := xc.d.(KeyedDiscovery); ok {
+		rpcAddr, err = kd.GetByKey(xc.mode, routingKeyFromContext(ctx))
+	} else {
+		rpcAddr, err = xc.d.Get(xc.mode)
+	}
+	if err != nil || (!tried[rpcAddr] && xc.available(rpcAddr)) {
+		return rpcAddr, err
+	}
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return "", err
+	}
+	untried := make([]string, 0, len(servers))
+	for _, server := range servers {
+		if !tried[server] && xc.available(server) {
+			untried = append(untried, server)
+		}
+	}
+	if len(untried) == 0 {
+		return rpcAddr, nil
+	}
+	return untried[rand.Intn(len(untried))], nil
+}
+
+func (xc *XClient) callWithRetry(policy *RetryPolicy, serviceMethod string, args interface{}, reply interface{}, ctx context.Context) error {
+	tried := make(map[string]bool)
+	backoff := policy.Backoff
+	var err error
+	for attempt := 0; attempt < policy.MaxAttempts || attempt == 0; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return err
+			}
+			backoff *= 2
+			if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+		rpcAddr, e := xc.pick(ctx, tried)
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			return err
+		}
+		tried[rpcAddr] = true
+		err = xc.callOnce(rpcAddr, policy.PerTryTimeout, serviceMethod, args, reply, ctx)
+		if err == nil || classify(err)&policy.RetryOn == 0 || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (xc *XClient) callOnce(rpcAddr string, timeout time.Duration, serviceMethod string, args interface{}, reply interface{}, ctx context.Context) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return xc.call(rpcAddr, serviceMethod, args, reply, ctx)
+}
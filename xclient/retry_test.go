@@ -0,0 +1,79 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	. "github.com/vlzx/zrpc"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{nil, 0},
+		{context.DeadlineExceeded, ErrClassTimeout},
+		{fmt.Errorf("rpc client: call timeout: %w", context.DeadlineExceeded), ErrClassTimeout},
+		{ServerError("rpc server: handle request timeout: expect within 1s"), ErrClassTimeout},
+		{ServerError("rpc server: overloaded: limit of server reached"), ErrClassOverload},
+		{ServerError("rpc server: rate limited: limit of server reached"), ErrClassRateLimit},
+		{ServerError("business error"), ErrClassServer},
+		{ErrShutdown, ErrClassConn},
+		{ErrBreakerOpen, ErrClassConn},
+		{io.EOF, ErrClassConn},
+		{errors.New("rpc client: connect timeout: expect within 1s"), ErrClassConn},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrClassConn},
+		{errors.New("unknown"), 0},
+	}
+	for _, c := range cases {
+		_assert(classify(c.err) == c.want, "classify(%v) = %d, expect %d", c.err, classify(c.err), c.want)
+	}
+}
+
+func TestXClient_Retry(t *testing.T) {
+	addrs, echos, stop := startServers(3)
+	defer stop()
+	xc := NewXClient(NewMultiServerDiscovery(addrs), RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+	calls := func() (total int64, servers int) {
+		for _, echo := range echos {
+			total += echo.numCalls()
+			if echo.numCalls() > 0 {
+				servers++
+			}
+			atomic.StoreInt64(&echo.calls, 0)
+		}
+		return
+	}
+
+	// ServerError is not retried by default
+	xc.SetRetryPolicy("Echo", DefaultRetryPolicy)
+	var reply string
+	err := xc.Call("Echo.Fail", 0, &reply, context.Background())
+	total, _ := calls()
+	_assert(err != nil && total == 1, "expect a single attempt, got %d", total)
+
+	xc.SetRetryPolicy("Echo.Fail", &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond * 20, MaxBackoff: time.Millisecond * 30, RetryOn: ErrClassServer})
+	start := time.Now()
+	err = xc.Call("Echo.Fail", 0, &reply, context.Background())
+	elapsed := time.Since(start)
+	total, servers := calls()
+	_assert(err != nil && total == 3 && servers == 3, "expect every attempt on another server, got %d calls on %d servers", total, servers)
+	_assert(elapsed >= time.Millisecond*50 && elapsed < time.Millisecond*200, "expect backoffs of 20ms and 30ms, took %s", elapsed)
+
+	// a broken server is retried elsewhere
+	xc.SetRetryPolicy("Echo.Fail", nil)
+	bad := NewXClient(NewMultiServerDiscovery([]string{"tcp@127.0.0.1:1", addrs[0]}), RoundRobinSelect, nil)
+	defer func() { _ = bad.Close() }()
+	bad.SetRetryPolicy("Echo", &RetryPolicy{MaxAttempts: 2, RetryOn: ErrClassConn})
+	for i := 0; i < 4; i++ {
+		err = bad.Call("Echo.Name", 0, &reply, context.Background())
+		_assert(err == nil && reply == "0", "expect a retry on the healthy server, got %v", err)
+	}
+}
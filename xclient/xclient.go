@@ -7,71 +7,182 @@ import (
 	"io"
 	"reflect"
 	"sync"
+	"time"
 )
 
 type XClient struct {
-	d       Discovery
-	mode    SelectMode
-	opt     *Option
-	mux     sync.Mutex // protect clients
-	clients map[string]*Client
+	d        Discovery
+	mode     SelectMode
+	opt      *Option
+	copt     *CallOption
+	mux      sync.Mutex // protect following
+	popt     *PoolOption
+	pools    map[string]*clientPool
+	stop     chan struct{} // stop the idle connection janitor
+	retries  map[string]*RetryPolicy
+	bopt     *BreakerOption
+	breakers map[string]*breaker
+	hedges   map[string]*HedgePolicy
+	latency  map[string]*latencyWindow
 }
 
 func (xc *XClient) Close() error {
 	xc.mux.Lock()
 	defer xc.mux.Unlock()
-	for key, client := range xc.clients {
-		_ = client.Close()
-		delete(xc.clients, key)
+	for key, pool := range xc.pools {
+		_ = pool.Close()
+		delete(xc.pools, key)
+	}
+	if xc.stop != nil {
+		close(xc.stop)
+		xc.stop = nil
 	}
 	return nil
 }
 
 var _ io.Closer = (*XClient)(nil)
 
-func NewXClient(d Discovery, mode SelectMode, opt *Option) *XClient {
+// NewXClient creates an XClient, the optional CallOption sets the call mode
+// of methods without a retry or hedge policy, Failfast by default
+func NewXClient(d Discovery, mode SelectMode, opt *Option, copts ...*CallOption) *XClient {
+	copt := DefaultCallOption
+	if len(copts) > 0 && copts[0] != nil {
+		copt = copts[0]
+	}
 	return &XClient{
 		d:       d,
 		mode:    mode,
 		opt:     opt,
-		clients: make(map[string]*Client),
+		copt:    copt,
+		popt:    DefaultPoolOption,
+		pools:   make(map[string]*clientPool),
+		retries: make(map[string]*RetryPolicy),
+		hedges:  make(map[string]*HedgePolicy),
+		latency: make(map[string]*latencyWindow),
 	}
 }
 
-func (xc *XClient) dial(rpcAddr string) (*Client, error) {
+// SetPoolOption sets how many connections are kept per server address,
+// it applies to existing pools as well
+func (xc *XClient) SetPoolOption(popt *PoolOption) {
+	if popt == nil {
+		popt = DefaultPoolOption
+	}
+	xc.mux.Lock()
+	defer xc.mux.Unlock()
+	xc.popt = popt
+	for _, pool := range xc.pools {
+		pool.setOption(popt)
+	}
+	if popt.IdleTimeout > 0 && xc.stop == nil {
+		xc.stop = make(chan struct{})
+		go xc.janitor(popt.IdleTimeout, xc.stop)
+	}
+}
+
+func (xc *XClient) janitor(interval time.Duration, stop chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		xc.mux.Lock()
+		pools := make([]*clientPool, 0, len(xc.pools))
+		for _, pool := range xc.pools {
+			pools = append(pools, pool)
+		}
+		xc.mux.Unlock()
+		for _, pool := range pools {
+			pool.evict()
+		}
+		xc.prunePools()
+	}
+}
+
+// prunePools closes the pools of addresses the discovery does not return anymore
+func (xc *XClient) prunePools() {
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return
+	}
+	current := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		current[server] = true
+	}
 	xc.mux.Lock()
 	defer xc.mux.Unlock()
-	client, ok := xc.clients[rpcAddr]
-	if ok && !client.IsAvailable() {
-		_ = client.Close()
-		delete(xc.clients, rpcAddr)
-		client = nil
-	}
-	if client == nil {
-		var err error
-		client, err = XDial(rpcAddr, xc.opt)
-		if err != nil {
-			return nil, err
+	for rpcAddr, pool := range xc.pools {
+		if !current[rpcAddr] {
+			_ = pool.Close()
+			delete(xc.pools, rpcAddr)
 		}
-		xc.clients[rpcAddr] = client
 	}
-	return client, nil
+}
+
+func (xc *XClient) dial(rpcAddr string) (*Client, error) {
+	xc.mux.Lock()
+	pool, ok := xc.pools[rpcAddr]
+	if !ok {
+		pool = newClientPool(rpcAddr, xc.opt, xc.popt)
+		xc.pools[rpcAddr] = pool
+	}
+	xc.mux.Unlock()
+	if !ok {
+		// a new address, the servers may have changed
+		xc.prunePools()
+	}
+	return pool.get()
 }
 
 func (xc *XClient) call(rpcAddr string, serviceMethod string, args interface{}, reply interface{}, ctx ...context.Context) error {
+	b := xc.getBreaker(rpcAddr)
+	probe, ok := uint64(0), true
+	if b != nil {
+		probe, ok = b.allow()
+	}
+	if !ok {
+		return ErrBreakerOpen
+	}
+	obs, _ := xc.d.(Observer)
+	if obs != nil {
+		obs.Start(rpcAddr)
+	}
+	start := time.Now()
 	client, err := xc.dial(rpcAddr)
-	if err != nil {
-		return err
+	if err == nil {
+		err = client.Call(serviceMethod, args, reply, ctx...)
+	}
+	latency := time.Since(start)
+	if obs != nil {
+		obs.Done(rpcAddr, latency, err)
+	}
+	if b != nil {
+		b.record(probe, err, latency)
 	}
-	return client.Call(serviceMethod, args, reply, ctx...)
+	return err
 }
 
 func (xc *XClient) Call(serviceMethod string, args interface{}, reply interface{}, ctx ...context.Context) error {
-	rpcAddr, err := xc.d.Get(xc.mode)
-	if err != nil {
-		return err
+	defaultCtx := context.Background()
+	if len(ctx) == 1 && ctx[0] != nil {
+		defaultCtx = ctx[0]
+	}
+	if rk, ok := args.(RoutingKeyer); ok && routingKeyFromContext(defaultCtx) == "" {
+		defaultCtx = WithRoutingKey(defaultCtx, rk.RoutingKey())
+	}
+	if copt, ok := callOptionFromContext(defaultCtx); ok {
+		return xc.callWithMode(copt, serviceMethod, args, reply, defaultCtx)
+	}
+	if policy := xc.hedgePolicy(serviceMethod); policy != nil {
+		return xc.callWithHedge(policy, serviceMethod, args, reply, defaultCtx)
+	}
+	if policy := xc.retryPolicy(serviceMethod); policy != nil {
+		return xc.callWithRetry(policy, serviceMethod, args, reply, defaultCtx)
 	}
-	return xc.call(rpcAddr, serviceMethod, args, reply, ctx...)
+	return xc.callWithMode(xc.copt, serviceMethod, args, reply, defaultCtx)
 }
 
 func (xc *XClient) Broadcast(serviceMethod string, args interface{}, reply interface{}, ctx ...context.Context) error {
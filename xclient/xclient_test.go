@@ -0,0 +1,68 @@
+package xclient
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/vlzx/zrpc"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+func _assert(condition bool, msg string, v ...interface{}) {
+	if !condition {
+		panic(fmt.Sprintf("assertion failed: "+msg, v...))
+	}
+}
+
+// Echo replies with the name of its server, after delay
+type Echo struct {
+	name  string
+	delay int64 // time.Duration, changed while serving
+	calls int64
+}
+
+func (e *Echo) Name(_ int, reply *string) error {
+	atomic.AddInt64(&e.calls, 1)
+	time.Sleep(time.Duration(atomic.LoadInt64(&e.delay)))
+	*reply = e.name
+	return nil
+}
+
+func (e *Echo) Fail(_ int, reply *string) error {
+	atomic.AddInt64(&e.calls, 1)
+	return errors.New("business error")
+}
+
+func (e *Echo) setDelay(d time.Duration) {
+	atomic.StoreInt64(&e.delay, int64(d))
+}
+
+func (e *Echo) numCalls() int64 {
+	return atomic.LoadInt64(&e.calls)
+}
+
+// startServer serves e on a random port and returns its tcp@ address
+func startServer(e *Echo) (string, *Server) {
+	server := NewServer()
+	_ = server.Register(e)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go server.Accept(l)
+	return "tcp@" + l.Addr().String(), server
+}
+
+// startServers starts n servers named "0", "1", ...
+func startServers(n int) ([]string, []*Echo, func()) {
+	addrs := make([]string, n)
+	echos := make([]*Echo, n)
+	servers := make([]*Server, n)
+	for i := range addrs {
+		echos[i] = &Echo{name: fmt.Sprint(i)}
+		addrs[i], servers[i] = startServer(echos[i])
+	}
+	return addrs, echos, func() {
+		for _, server := range servers {
+			server.Shutdown()
+		}
+	}
+}